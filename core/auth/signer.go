@@ -44,6 +44,14 @@ var (
 	}
 )
 
+// unsignedHeaders are never signed even if they are in HeadersToSign, the trace context may be changed
+// by the proxies on the way
+var unsignedHeaders = map[string]struct{}{
+	strings.ToLower(metadata.RequestKeyAuthorization): {},
+	metadata.RequestKeyTraceParent:                    {},
+	metadata.RequestKeyTraceState:                     {},
+}
+
 // Signer abstracts the entity that implements the `Sign` method
 type Signer interface {
 	// Sign the given Request with the Credentials and SignOptions
@@ -103,8 +111,6 @@ func (a *AttainsV1Signer) Sign(req *http.Request, ll logger.Interface, cred *Att
 	ll.Debug(req.Context(), "signKeyInfo: %v", signKeyInfo)
 	signKey := cryptoutil.HmacSha256Hex(secretAccessKey, signKeyInfo)
 
-	ll.Debug(req.Context(), "signKey: %v", signKey)
-
 	canonicalUri := getCanonicalURIPath(req.URL.Path)
 	canonicalQueryString := getCanonicalQueryString(req.URL.Query())
 	canonicalHeaders, signedHeadersArr := getCanonicalHeaders(req.Header, opt.HeadersToSign)
@@ -124,7 +130,7 @@ func (a *AttainsV1Signer) Sign(req *http.Request, ll logger.Interface, cred *Att
 
 	// Generate auth string and add to the reqeust header
 	authStr := signKeyInfo + "/" + signedHeaders + "/" + signature
-	ll.Info(req.Context(), "Authorization=%s", authStr)
+	ll.Debug(req.Context(), "Signed headers: %s", signedHeaders)
 
 	req.Header.Set(metadata.RequestKeyAuthorization, authStr)
 
@@ -172,7 +178,7 @@ func getCanonicalHeaders(headers http.Header, headersToSign map[string]struct{})
 	signHeaders := make([]string, 0, len(headersToSign))
 	for k, vv := range headers {
 		headKey := strings.ToLower(k)
-		if headKey == strings.ToLower(metadata.RequestKeyAuthorization) {
+		if _, unsigned := unsignedHeaders[headKey]; unsigned {
 			continue
 		}
 		_, headExists := headersToSign[headKey]
@@ -17,13 +17,29 @@
 
 package errors
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type AttainsError interface {
 	error
 }
 
+// ClientErrorKind classify the AttainsClientError
+type ClientErrorKind string
+
+const (
+	ClientErrorKindGeneral          ClientErrorKind = "General"
+	ClientErrorKindCircuitOpen      ClientErrorKind = "CircuitOpen"
+	ClientErrorKindRateLimited      ClientErrorKind = "RateLimited"
+	ClientErrorKindResponseTooLarge ClientErrorKind = "ResponseTooLarge"
+	ClientErrorKindNetwork          ClientErrorKind = "Network"
+)
+
 type AttainsClientError struct {
+	kind    ClientErrorKind
 	message string
 }
 
@@ -31,12 +47,99 @@ func (e *AttainsClientError) Error() string {
 	return e.message
 }
 
+func (e *AttainsClientError) Kind() ClientErrorKind {
+	return e.kind
+}
+
 func NewAttainsClientError(text string) error {
+	return NewAttainsClientErrorWithKind(ClientErrorKindGeneral, text)
+}
+
+func NewAttainsClientErrorWithKind(kind ClientErrorKind, text string) error {
 	return &AttainsClientError{
+		kind:    kind,
 		message: text,
 	}
 }
 
+// IsClientErrorKind check whether the err is an AttainsClientError of the kind
+func IsClientErrorKind(err error, kind ClientErrorKind) bool {
+	if realErr, ok := err.(*AttainsClientError); ok {
+		return realErr.kind == kind
+	}
+	return false
+}
+
+// AttainsCanceledError the error returned when the context of a call is canceled or its deadline is exceeded
+type AttainsCanceledError struct {
+	attempts int
+	cause    error
+	lastErr  error
+}
+
+func (e *AttainsCanceledError) Error() string {
+	ret := fmt.Sprintf("request aborted after %d attempts: %v", e.attempts, e.cause)
+	if e.lastErr != nil {
+		ret += fmt.Sprintf(", last error: %v", e.lastErr)
+	}
+	return ret
+}
+
+// Unwrap return context.Canceled or context.DeadlineExceeded
+func (e *AttainsCanceledError) Unwrap() error {
+	return e.cause
+}
+
+// Attempts return the number of attempts made before the call was aborted
+func (e *AttainsCanceledError) Attempts() int {
+	return e.attempts
+}
+
+// LastError return the error of the last attempt, nil if no attempt failed
+func (e *AttainsCanceledError) LastError() error {
+	return e.lastErr
+}
+
+func NewAttainsCanceledError(attempts int, cause, lastErr error) error {
+	return &AttainsCanceledError{
+		attempts: attempts,
+		cause:    cause,
+		lastErr:  lastErr,
+	}
+}
+
+// AttainsMultiError the errors of a group of calls, e.g. returned by httpclient.WaitAll
+type AttainsMultiError struct {
+	errs []error
+}
+
+func (e *AttainsMultiError) Error() string {
+	var failed []string
+	for i, err := range e.errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("[%d] %v", i, err))
+		}
+	}
+	return fmt.Sprintf("%d of %d calls failed: %s", len(failed), len(e.errs), strings.Join(failed, "; "))
+}
+
+// Errors return the error of every call in order, nil for the succeeded ones
+func (e *AttainsMultiError) Errors() []error {
+	return e.errs
+}
+
+// NewAttainsMultiError return nil if none of errs is set
+func NewAttainsMultiError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return &AttainsMultiError{
+				errs: errs,
+			}
+		}
+	}
+	return nil
+}
+
 type AttainsServiceError struct {
 	code    int64
 	message string
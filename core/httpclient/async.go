@@ -0,0 +1,143 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+)
+
+// AttainsAsyncHttpClient an AttainsHttpClient running the asynchronous calls on its worker pool,
+// implemented by DefaultAttainsHttpClient
+type AttainsAsyncHttpClient interface {
+	AttainsHttpClient
+	// SendRequestAsync send a request on the worker pool, the result of the future is the response,
+	// it blocks until a worker is free
+	SendRequestAsync(AttainsRequest, AttainsResponse) *Future
+	// Submit run fn on the worker pool, it blocks until a worker is free
+	Submit(ctx context.Context, fn func() (interface{}, error)) *Future
+}
+
+// Future the pending result of an asynchronous call
+type Future struct {
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+func newFuture() *Future {
+	return &Future{
+		done: make(chan struct{}),
+	}
+}
+
+func (f *Future) complete(result interface{}, err error) {
+	f.result = result
+	f.err = err
+	close(f.done)
+}
+
+// Done return a channel closed when the call completes
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait wait for the call to complete and return its error, or the error of ctx if ctx is done first
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get wait for the call to complete and return its result and error
+func (f *Future) Get(ctx context.Context) (interface{}, error) {
+	if err := f.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return f.result, nil
+}
+
+// Waiter a pending call, e.g. a Future or the typed futures of the services
+type Waiter interface {
+	Wait(ctx context.Context) error
+}
+
+// WaitAll wait for all the calls and return an errors.AttainsMultiError holding the error of every call
+// in order if any of them failed, the calls still running when ctx is done fail with the error of ctx
+func WaitAll(ctx context.Context, waiters ...Waiter) error {
+	errs := make([]error, len(waiters))
+	for i, w := range waiters {
+		errs[i] = w.Wait(ctx)
+	}
+	return errors.NewAttainsMultiError(errs)
+}
+
+func newWorkers(size int) chan struct{} {
+	if size <= 0 {
+		size = metadata.DefaultAsyncWorkers
+	}
+	return make(chan struct{}, size)
+}
+
+// Submit run fn once a worker is free, the caller is blocked until then so that the pending calls
+// hold no goroutines, the future fails without running fn if ctx is done before
+func (d *DefaultAttainsHttpClient) Submit(ctx context.Context, fn func() (interface{}, error)) *Future {
+	return runAsync(ctx, d.workers, fn)
+}
+
+// RunAsync run fn in a new goroutine without a worker pool, for the clients not implementing
+// AttainsAsyncHttpClient, the future fails without running fn if ctx is done already
+func RunAsync(ctx context.Context, fn func() (interface{}, error)) *Future {
+	return runAsync(ctx, nil, fn)
+}
+
+// runAsync take one of the workers, if any, and run fn in a new goroutine holding it
+func runAsync(ctx context.Context, workers chan struct{}, fn func() (interface{}, error)) *Future {
+	f := newFuture()
+	if workers == nil {
+		if err := ctx.Err(); err != nil {
+			f.complete(nil, errors.NewAttainsCanceledError(0, err, nil))
+			return f
+		}
+		go func() {
+			f.complete(fn())
+		}()
+		return f
+	}
+	select {
+	case workers <- struct{}{}:
+	case <-ctx.Done():
+		f.complete(nil, errors.NewAttainsCanceledError(0, ctx.Err(), nil))
+		return f
+	}
+	go func() {
+		defer func() { <-workers }()
+		f.complete(fn())
+	}()
+	return f
+}
+
+// SendRequestAsync send the request on the worker pool, the result of the future is the response
+func (d *DefaultAttainsHttpClient) SendRequestAsync(request AttainsRequest, response AttainsResponse) *Future {
+	return d.Submit(request.GetContext(), func() (interface{}, error) {
+		return response, d.SendRequest(request, response)
+	})
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient_test
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/auth"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubmitBlocksWithoutFreeWorker(t *testing.T) {
+	conf := newTestConfig("sms.example.invalid")
+	conf.AsyncWorkers = 2
+	client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, conf).(httpclient.AttainsAsyncHttpClient)
+
+	release := make(chan struct{})
+	var running int32
+	busy := func() (interface{}, error) {
+		atomic.AddInt32(&running, 1)
+		<-release
+		return "busy", nil
+	}
+	futures := []*httpclient.Future{
+		client.Submit(context.Background(), busy),
+		client.Submit(context.Background(), busy),
+	}
+
+	// The pool is full, the submission waits in the caller instead of parking a goroutine
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var ran int32
+	future := client.Submit(ctx, func() (interface{}, error) {
+		atomic.StoreInt32(&ran, 1)
+		return nil, nil
+	})
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Submit returned after %v, want it to block until ctx is done", elapsed)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("goroutines = %d after the blocked submission, want at most %d", n, goroutines)
+	}
+	if err := future.Wait(context.Background()); err == nil {
+		t.Error("err = nil, want the error of ctx")
+	}
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Error("fn ran although ctx was done before a worker was free")
+	}
+	if n := atomic.LoadInt32(&running); n != 2 {
+		t.Errorf("running = %d, want 2", n)
+	}
+
+	close(release)
+	for _, f := range futures {
+		if result, err := f.Get(context.Background()); err != nil || result != "busy" {
+			t.Errorf("result = %v, %v, want busy", result, err)
+		}
+	}
+	if result, err := client.Submit(context.Background(), func() (interface{}, error) {
+		return "done", nil
+	}).Get(context.Background()); err != nil || result != "done" {
+		t.Errorf("result = %v, %v, want done once the workers are free", result, err)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState the state of a circuit breaker
+type CircuitState int
+
+const (
+	CircuitStateClosed CircuitState = iota
+	CircuitStateOpen
+	CircuitStateHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitStateClosed:
+		return "closed"
+	case CircuitStateOpen:
+		return "open"
+	case CircuitStateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// CircuitBreakerSettings configures the circuit breaker, zero values are replaced by the defaults
+type CircuitBreakerSettings struct {
+	// FailureRatio open the circuit when failures/requests reaches it in the closed state
+	FailureRatio float64
+	// MinRequests the requests needed in the closed state before the ratio is evaluated
+	MinRequests int
+	// OpenDuration how long the circuit stays open before letting probes through
+	OpenDuration time.Duration
+	// Interval the period after which the counts of the closed state are cleared
+	Interval time.Duration
+	// HalfOpenMaxRequests the probes allowed in the half-open state, all must succeed to close the circuit
+	HalfOpenMaxRequests int
+	// IsFailure decide whether the error of an attempt counts as failure,
+	// transport errors and 5xx service errors by default
+	IsFailure func(error) bool
+	// OnStateChange is called after the circuit of a host changed its state
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+// WithCircuitBreaker enable a circuit breaker per endpoint host, calls fail fast with a
+// ClientErrorKindCircuitOpen error while the circuit of the host is open
+func WithCircuitBreaker(settings CircuitBreakerSettings) ClientOption {
+	return func(d *DefaultAttainsHttpClient) {
+		breaker := newCircuitBreaker(settings, d.GetLogger, d.isDryRun)
+		d.attemptMiddlewares = append(d.attemptMiddlewares, breaker.middleware)
+	}
+}
+
+func defaultIsFailure(err error) bool {
+	switch realErr := err.(type) {
+	case net.Error:
+		return true
+	case *errors.AttainsServiceError:
+		return realErr.Code() >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+type circuit struct {
+	state      CircuitState
+	generation uint64
+	requests   int
+	failures   int
+	successes  int
+	expiry     time.Time
+}
+
+type circuitBreaker struct {
+	settings  CircuitBreakerSettings
+	getLogger func() logger.Interface
+	// skip the requests which are not sent, e.g. the dry runs
+	skip func(AttainsRequest) bool
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func newCircuitBreaker(settings CircuitBreakerSettings, getLogger func() logger.Interface, skip func(AttainsRequest) bool) *circuitBreaker {
+	if settings.FailureRatio <= 0 {
+		settings.FailureRatio = metadata.DefaultCircuitFailureRatio
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = metadata.DefaultCircuitMinRequests
+	}
+	if settings.OpenDuration <= 0 {
+		settings.OpenDuration = metadata.DefaultCircuitOpenDuration
+	}
+	if settings.Interval <= 0 {
+		settings.Interval = metadata.DefaultCircuitInterval
+	}
+	if settings.HalfOpenMaxRequests <= 0 {
+		settings.HalfOpenMaxRequests = metadata.DefaultCircuitHalfOpenMaxRequests
+	}
+	if settings.IsFailure == nil {
+		settings.IsFailure = defaultIsFailure
+	}
+	return &circuitBreaker{
+		settings:  settings,
+		getLogger: getLogger,
+		skip:      skip,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+func (b *circuitBreaker) middleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		if b.skip(request) {
+			return next(request, response)
+		}
+		ctx := request.GetContext()
+		host := request.Build().URL.Host
+		generation, err := b.allow(ctx, host)
+		if err != nil {
+			return err
+		}
+		err = next(request, response)
+		// A call abandoned by the caller says nothing about the endpoint
+		if ctx.Err() != nil {
+			b.release(host, generation)
+			return err
+		}
+		b.done(ctx, host, generation, err != nil && b.settings.IsFailure(err))
+		return err
+	}
+}
+
+func (b *circuitBreaker) allow(ctx context.Context, host string) (uint64, error) {
+	b.mu.Lock()
+	now := time.Now()
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{expiry: now.Add(b.settings.Interval)}
+		b.circuits[host] = c
+	}
+	notify := b.refresh(ctx, host, c, now)
+	state, generation := c.state, c.generation
+	allowed := true
+	switch state {
+	case CircuitStateOpen:
+		allowed = false
+	case CircuitStateHalfOpen:
+		if c.requests >= b.settings.HalfOpenMaxRequests {
+			allowed = false
+		} else {
+			c.requests++
+		}
+	}
+	b.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+	if !allowed {
+		return 0, errors.NewAttainsClientErrorWithKind(errors.ClientErrorKindCircuitOpen,
+			fmt.Sprintf("circuit breaker is %s for host %s", state, host))
+	}
+	return generation, nil
+}
+
+func (b *circuitBreaker) done(ctx context.Context, host string, generation uint64, failed bool) {
+	b.mu.Lock()
+	var notify func()
+	// Ignore the results of the attempts started in a previous state
+	if c, ok := b.circuits[host]; ok && c.generation == generation {
+		now := time.Now()
+		switch c.state {
+		case CircuitStateClosed:
+			c.requests++
+			if failed {
+				c.failures++
+			}
+			if c.requests >= b.settings.MinRequests && float64(c.failures)/float64(c.requests) >= b.settings.FailureRatio {
+				notify = b.setState(ctx, host, c, CircuitStateOpen, now)
+			}
+		case CircuitStateHalfOpen:
+			if failed {
+				notify = b.setState(ctx, host, c, CircuitStateOpen, now)
+				break
+			}
+			c.successes++
+			if c.successes >= b.settings.HalfOpenMaxRequests {
+				notify = b.setState(ctx, host, c, CircuitStateClosed, now)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
+
+// release give back the probe slot of an attempt without result
+func (b *circuitBreaker) release(host string, generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[host]; ok && c.generation == generation && c.state == CircuitStateHalfOpen {
+		c.requests--
+	}
+}
+
+// refresh move the circuit to the next period when its current one expires, must hold the lock
+func (b *circuitBreaker) refresh(ctx context.Context, host string, c *circuit, now time.Time) func() {
+	switch c.state {
+	case CircuitStateClosed:
+		if now.After(c.expiry) {
+			c.generation++
+			c.requests, c.failures, c.successes = 0, 0, 0
+			c.expiry = now.Add(b.settings.Interval)
+		}
+	case CircuitStateOpen:
+		if now.After(c.expiry) {
+			return b.setState(ctx, host, c, CircuitStateHalfOpen, now)
+		}
+	}
+	return nil
+}
+
+// setState change the state of the circuit and return the notification to run after unlocking
+func (b *circuitBreaker) setState(ctx context.Context, host string, c *circuit, state CircuitState, now time.Time) func() {
+	from := c.state
+	c.state = state
+	c.generation++
+	c.requests, c.failures, c.successes = 0, 0, 0
+	switch state {
+	case CircuitStateClosed:
+		c.expiry = now.Add(b.settings.Interval)
+	case CircuitStateOpen:
+		c.expiry = now.Add(b.settings.OpenDuration)
+	default:
+		c.expiry = time.Time{}
+	}
+	return func() {
+		b.getLogger().Warn(ctx, "Circuit breaker of %s changed from %s to %s", host, from, state)
+		if b.settings.OnStateChange != nil {
+			b.settings.OnStateChange(host, from, state)
+		}
+	}
+}
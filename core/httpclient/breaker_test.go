@@ -0,0 +1,225 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const breakerHost = "sms.example.invalid"
+
+// breakerRun the breaker under test and the generations returned by its allow calls, in order
+type breakerRun struct {
+	t           *testing.T
+	b           *circuitBreaker
+	generations []uint64
+}
+
+// breakerStep one step of a breaker case, the attempts are referred to by the order of their allow calls
+type breakerStep func(r *breakerRun)
+
+func allowed(r *breakerRun, wantAllowed bool) {
+	generation, err := r.b.allow(context.Background(), breakerHost)
+	if wantAllowed && err != nil {
+		r.t.Fatalf("allow %d: %v, want allowed", len(r.generations), err)
+	}
+	if !wantAllowed && !errors.IsClientErrorKind(err, errors.ClientErrorKindCircuitOpen) {
+		r.t.Fatalf("allow %d: err = %v, want the circuit open error", len(r.generations), err)
+	}
+	r.generations = append(r.generations, generation)
+}
+
+func allow() breakerStep {
+	return func(r *breakerRun) { allowed(r, true) }
+}
+
+func deny() breakerStep {
+	return func(r *breakerRun) { allowed(r, false) }
+}
+
+func succeed(attempt int) breakerStep {
+	return func(r *breakerRun) { r.b.done(context.Background(), breakerHost, r.generations[attempt], false) }
+}
+
+func fail(attempt int) breakerStep {
+	return func(r *breakerRun) { r.b.done(context.Background(), breakerHost, r.generations[attempt], true) }
+}
+
+func release(attempt int) breakerStep {
+	return func(r *breakerRun) { r.b.release(breakerHost, r.generations[attempt]) }
+}
+
+func sleep(d time.Duration) breakerStep {
+	return func(*breakerRun) { time.Sleep(d) }
+}
+
+func wantState(state CircuitState) breakerStep {
+	return func(r *breakerRun) {
+		r.b.mu.Lock()
+		got := r.b.circuits[breakerHost].state
+		r.b.mu.Unlock()
+		if got != state {
+			r.t.Fatalf("state = %s, want %s", got, state)
+		}
+	}
+}
+
+func transition(from, to CircuitState) string {
+	return fmt.Sprintf("%s->%s", from, to)
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	const openDuration = 30 * time.Millisecond
+	const expired = openDuration + 20*time.Millisecond
+	var (
+		closed   = CircuitStateClosed
+		open     = CircuitStateOpen
+		halfOpen = CircuitStateHalfOpen
+	)
+	cases := []struct {
+		name     string
+		settings CircuitBreakerSettings
+		steps    []breakerStep
+		want     []string
+	}{
+		{
+			name:     "closed to open on the failure ratio",
+			settings: CircuitBreakerSettings{FailureRatio: 0.5, MinRequests: 4},
+			steps: []breakerStep{
+				allow(), allow(), allow(), allow(),
+				fail(0), succeed(1), fail(2), wantState(closed),
+				succeed(3), wantState(open), deny(),
+			},
+			want: []string{transition(closed, open)},
+		},
+		{
+			name:     "stays closed below the min requests",
+			settings: CircuitBreakerSettings{FailureRatio: 0.5, MinRequests: 3},
+			steps:    []breakerStep{allow(), fail(0), allow(), fail(1), wantState(closed), allow()},
+		},
+		{
+			name:     "stays closed below the failure ratio",
+			settings: CircuitBreakerSettings{FailureRatio: 0.5, MinRequests: 3},
+			steps:    []breakerStep{allow(), allow(), allow(), fail(0), succeed(1), succeed(2), wantState(closed)},
+		},
+		{
+			name:     "interval clears the counts",
+			settings: CircuitBreakerSettings{FailureRatio: 1, MinRequests: 2, Interval: openDuration},
+			steps:    []breakerStep{allow(), fail(0), sleep(expired), allow(), fail(1), wantState(closed)},
+		},
+		{
+			name:     "open to half-open after the open duration",
+			settings: CircuitBreakerSettings{FailureRatio: 1, MinRequests: 1, OpenDuration: openDuration, HalfOpenMaxRequests: 1},
+			steps: []breakerStep{
+				allow(), fail(0), deny(), sleep(expired),
+				allow(), wantState(halfOpen), succeed(2), wantState(closed),
+			},
+			want: []string{transition(closed, open), transition(open, halfOpen), transition(halfOpen, closed)},
+		},
+		{
+			name:     "probes are limited in half-open",
+			settings: CircuitBreakerSettings{FailureRatio: 1, MinRequests: 1, OpenDuration: openDuration, HalfOpenMaxRequests: 2},
+			steps: []breakerStep{
+				allow(), fail(0), sleep(expired),
+				allow(), allow(), deny(),
+				succeed(1), wantState(halfOpen), succeed(2), wantState(closed),
+			},
+			want: []string{transition(closed, open), transition(open, halfOpen), transition(halfOpen, closed)},
+		},
+		{
+			name:     "failed probe opens the circuit again",
+			settings: CircuitBreakerSettings{FailureRatio: 1, MinRequests: 1, OpenDuration: openDuration, HalfOpenMaxRequests: 2},
+			steps: []breakerStep{
+				allow(), fail(0), sleep(expired),
+				allow(), allow(), succeed(1), fail(2), wantState(open), deny(),
+			},
+			want: []string{transition(closed, open), transition(open, halfOpen), transition(halfOpen, open)},
+		},
+		{
+			name:     "release frees the probe slot",
+			settings: CircuitBreakerSettings{FailureRatio: 1, MinRequests: 1, OpenDuration: openDuration, HalfOpenMaxRequests: 1},
+			steps: []breakerStep{
+				allow(), fail(0), sleep(expired),
+				allow(), deny(), release(1), wantState(halfOpen),
+				allow(), succeed(3), wantState(closed),
+			},
+			want: []string{transition(closed, open), transition(open, halfOpen), transition(halfOpen, closed)},
+		},
+		{
+			name:     "results of a previous state are dropped",
+			settings: CircuitBreakerSettings{FailureRatio: 1, MinRequests: 1, OpenDuration: openDuration, HalfOpenMaxRequests: 1},
+			steps: []breakerStep{
+				allow(), allow(), fail(0), wantState(open), sleep(expired),
+				allow(), release(1), deny(), fail(1), wantState(halfOpen),
+				succeed(2), wantState(closed),
+			},
+			want: []string{transition(closed, open), transition(open, halfOpen), transition(halfOpen, closed)},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			settings := c.settings
+			settings.OnStateChange = func(host string, from, to CircuitState) {
+				if host != breakerHost {
+					t.Errorf("state change of host %s, want %s", host, breakerHost)
+				}
+				got = append(got, transition(from, to))
+			}
+			r := &breakerRun{
+				t: t,
+				b: newCircuitBreaker(settings, func() logger.Interface { return logger.Discard }, func(AttainsRequest) bool { return false }),
+			}
+			for _, step := range c.steps {
+				step(r)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("state changes = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerReleaseOnCancel(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerSettings{FailureRatio: 1, MinRequests: 1, OpenDuration: 30 * time.Millisecond, HalfOpenMaxRequests: 1},
+		func() logger.Interface { return logger.Discard }, func(AttainsRequest) bool { return false })
+	r := &breakerRun{t: t, b: b}
+	allow()(r)
+	fail(0)(r)
+	sleep(50 * time.Millisecond)(r)
+
+	// The caller gives up on the probe, which must neither open the circuit nor keep its slot
+	ctx, cancel := context.WithCancel(context.Background())
+	request := NewDefaultAttainsRequest(ctx, nil)
+	request.Build().URL.Host = breakerHost
+	handler := b.middleware(func(AttainsRequest, AttainsResponse) error {
+		cancel()
+		return context.Canceled
+	})
+	if err := handler(request, NewDefaultAttainsResponse(&struct{}{})); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	wantState(CircuitStateHalfOpen)(r)
+	allow()(r)
+}
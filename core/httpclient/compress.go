@@ -0,0 +1,64 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net/http"
+)
+
+// shouldCompress check whether to compress the body of the request by the config
+func (d *DefaultAttainsHttpClient) shouldCompress(req *http.Request, size int64) bool {
+	opt := d.conf.Compression
+	if opt == nil || req.Header.Get(metadata.RequestKeyContentEncoding) != "" {
+		return false
+	}
+	// The digest given by the caller covers the plain body
+	if _, exist := req.Header[metadata.RequestKeyContentMd5]; exist {
+		return false
+	}
+	if _, exist := req.Header[metadata.RequestKeyContentLength]; exist {
+		return false
+	}
+	threshold := opt.Threshold
+	if threshold <= 0 {
+		threshold = metadata.DefaultCompressionThreshold
+	}
+	return size >= int64(threshold)
+}
+
+// compressBody gzip the body with the level, gzip.DefaultCompression if zero
+func compressBody(body []byte, level int) ([]byte, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
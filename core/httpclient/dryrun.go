@@ -0,0 +1,232 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DryRunError the error of a dry run call, holding the request built and signed
+type DryRunError struct {
+	Request *http.Request
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("dry run, %s %s is not sent", e.Request.Method, e.Request.URL.String())
+}
+
+// DryRunRequest return the request of a dry run call, false if err is not a DryRunError
+func DryRunRequest(err error) (*http.Request, bool) {
+	if e, ok := err.(*DryRunError); ok {
+		return e.Request, true
+	}
+	return nil, false
+}
+
+// isDryRun check whether the request is built and signed without being sent, such a request is kept out
+// of the rate limits, the circuit breakers and the endpoint health
+func (d *DefaultAttainsHttpClient) isDryRun(request AttainsRequest) bool {
+	return d.conf.DryRun || extend(request).IsDryRun()
+}
+
+// dryRun stop the attempt before sending, the body of the request is left readable for the export
+func (d *DefaultAttainsHttpClient) dryRun(req *http.Request) error {
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			req.Body.Close()
+			req.Body = body
+		}
+	}
+	d.GetLogger().Debug(req.Context(), "Dry run %s %s", req.Method, req.URL.String())
+	return &DryRunError{Request: req}
+}
+
+// requestBody read the body of the request without consuming it
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// sortedHeaderKeys the header keys in order, the Host header is skipped since it is sent from the url
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		if http.CanonicalHeaderKey(k) != "Host" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CurlCommand export the request as a curl command, e.g. to replay a dry run call
+func CurlCommand(req *http.Request) (string, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{"curl", "-X", req.Method, shellQuote([]byte(req.URL.String()))}
+	for _, k := range sortedHeaderKeys(req.Header) {
+		for _, v := range req.Header[k] {
+			parts = append(parts, "-H", shellQuote([]byte(k+": "+v)))
+		}
+	}
+	if len(body) > 0 {
+		parts = append(parts, "--data-binary", shellQuote(body))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// shellQuote quote s for a POSIX shell, or for bash with $” if s is not printable, e.g. a gzip body
+func shellQuote(s []byte) string {
+	if utf8.Valid(s) && bytes.IndexByte(s, 0) < 0 {
+		return "'" + strings.Replace(string(s), "'", `'\''`, -1) + "'"
+	}
+	var b strings.Builder
+	b.WriteString("$'")
+	for _, c := range s {
+		fmt.Fprintf(&b, "\\x%02x", c)
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// HARNameValue a header or query parameter of a HAR entry
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData the body of a HAR request
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARRequest the request of a HAR entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARContent the body of a HAR response
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// HARResponse the response of a HAR entry, empty for a request not sent
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARTimings the timings of a HAR entry
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HAREntry an entry of the HTTP Archive 1.2 format, marshal it to json for the HAR viewers
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// NewHAREntry export the request as a HAR entry without response, e.g. to review a dry run call
+func NewHAREntry(req *http.Request) (*HAREntry, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	entry := &HAREntry{
+		StartedDateTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    int64(len(body)),
+		},
+		Response: HARResponse{
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+	for _, k := range sortedHeaderKeys(req.Header) {
+		for _, v := range req.Header[k] {
+			entry.Request.Headers = append(entry.Request.Headers, HARNameValue{Name: k, Value: v})
+		}
+	}
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	if len(body) > 0 {
+		entry.Request.PostData = &HARPostData{
+			MimeType: req.Header.Get(metadata.RequestKeyContentType),
+			Text:     string(body),
+		}
+	}
+	return entry, nil
+}
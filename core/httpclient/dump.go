@@ -0,0 +1,260 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// DefaultRedactHeaders the headers redacted in the wire dump if WireDumpOptions.RedactHeaders is nil
+	DefaultRedactHeaders = []string{metadata.RequestKeyAuthorization}
+	// DefaultRedactFields the json fields and query parameters redacted in the wire dump if
+	// WireDumpOptions.RedactFields is nil, e.g. the mobile numbers and the template variables of the sms
+	DefaultRedactFields = []string{"mobile", "contentVars", "secretAccessKey"}
+)
+
+const redacted = "***"
+
+// wireDumper log the attempts with the sensitive values redacted
+type wireDumper struct {
+	maxBody int
+	headers map[string]bool
+	fields  map[string]bool
+	// pattern redact the fields of a truncated json body which can not be decoded
+	pattern *regexp.Regexp
+}
+
+func newWireDumper(opts *config.WireDumpOptions) *wireDumper {
+	if opts == nil {
+		return nil
+	}
+	w := &wireDumper{
+		maxBody: opts.MaxBodyBytes,
+		headers: map[string]bool{},
+		fields:  map[string]bool{},
+	}
+	if w.maxBody == 0 {
+		w.maxBody = metadata.DefaultLogBodyLimit
+	}
+	headers, fields := opts.RedactHeaders, opts.RedactFields
+	if headers == nil {
+		headers = DefaultRedactHeaders
+	}
+	if fields == nil {
+		fields = DefaultRedactFields
+	}
+	for _, h := range headers {
+		w.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	var quoted []string
+	for _, f := range fields {
+		w.fields[strings.ToLower(f)] = true
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	if len(quoted) > 0 {
+		w.pattern = regexp.MustCompile(`(?i)"(` + strings.Join(quoted, "|") +
+			`)"\s*:\s*(?:"(?:[^"\\]|\\.)*"?|\{[^{}]*\}?|\[[^\[\]]*\]?|[^,}\]\s]+)`)
+	}
+	return w
+}
+
+// dumpRequest log the request of an attempt
+func (d *DefaultAttainsHttpClient) dumpRequest(req *http.Request) {
+	w := d.dumper
+	var b strings.Builder
+	u := *req.URL
+	u.RawQuery = w.redactQuery(u.Query())
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, u.String())
+	w.writeHeaders(&b, ">", req.Header)
+	if req.GetBody != nil && w.maxBody > 0 {
+		if body, err := req.GetBody(); err == nil {
+			var r io.Reader = body
+			if req.Header.Get(metadata.RequestKeyContentEncoding) == metadata.ContentEncodingGzip {
+				if zr, err := gzip.NewReader(body); err == nil {
+					r = zr
+				}
+			}
+			buf, more := readPrefix(r, w.maxBody)
+			body.Close()
+			w.writeBody(&b, ">", buf, more)
+			// A seeker shared with the attempt is read from the start again
+			if fresh, err := req.GetBody(); err == nil {
+				req.Body.Close()
+				req.Body = fresh
+			}
+		}
+	}
+	d.GetLogger().Info(req.Context(), "%s", d.redactSecrets(b.String()))
+}
+
+// dumpResponse log the response or the error of an attempt, the body read is put back for the parsing
+func (d *DefaultAttainsHttpClient) dumpResponse(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	w := d.dumper
+	var b strings.Builder
+	if err != nil {
+		fmt.Fprintf(&b, "< %s %s failed after %v: %v\n", req.Method, req.URL.Path, elapsed, err)
+	} else {
+		fmt.Fprintf(&b, "< %s %s (%v)\n", resp.Proto, resp.Status, elapsed)
+		w.writeHeaders(&b, "<", resp.Header)
+		if resp.Body != nil && w.maxBody > 0 {
+			buf, more := readPrefix(resp.Body, w.maxBody)
+			resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(buf), resp.Body), Closer: resp.Body}
+			w.writeBody(&b, "<", buf, more)
+		}
+	}
+	d.GetLogger().Info(req.Context(), "%s", d.redactSecrets(b.String()))
+}
+
+// redactSecrets redact the secret key wherever it appears
+func (d *DefaultAttainsHttpClient) redactSecrets(s string) string {
+	if cred := d.conf.Credentials; cred != nil && cred.SecretAccessKey != "" {
+		s = strings.Replace(s, cred.SecretAccessKey, redacted, -1)
+	}
+	return s
+}
+
+func (w *wireDumper) writeHeaders(b *strings.Builder, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(header[k], ", ")
+		if w.headers[http.CanonicalHeaderKey(k)] {
+			v = redacted
+		}
+		fmt.Fprintf(b, "%s %s: %s\n", prefix, k, v)
+	}
+}
+
+// redactHeader copy the header with the values of the redacted headers replaced
+func (w *wireDumper) redactHeader(header http.Header) http.Header {
+	copied := make(http.Header, len(header))
+	for k, v := range header {
+		if w.headers[http.CanonicalHeaderKey(k)] {
+			v = []string{redacted}
+		}
+		copied[k] = v
+	}
+	return copied
+}
+
+func (w *wireDumper) writeBody(b *strings.Builder, prefix string, buf []byte, more bool) {
+	if len(buf) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s\n%s %s", prefix, prefix, w.redactBody(buf, more))
+	if more {
+		fmt.Fprintf(b, "...(truncated at %d bytes)", len(buf))
+	}
+	b.WriteString("\n")
+}
+
+func (w *wireDumper) redactQuery(query map[string][]string) string {
+	values := make([]string, 0, len(query))
+	for k, vs := range query {
+		for _, v := range vs {
+			if w.fields[strings.ToLower(k)] {
+				v = redacted
+			}
+			values = append(values, k+"="+v)
+		}
+	}
+	sort.Strings(values)
+	return strings.Join(values, "&")
+}
+
+// redactBody redact the fields of a json body, a truncated body is redacted by pattern
+func (w *wireDumper) redactBody(buf []byte, truncated bool) string {
+	if !truncated {
+		var v interface{}
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.UseNumber()
+		if decoder.Decode(&v) == nil {
+			if out, err := json.Marshal(w.redactValue(v)); err == nil {
+				return string(out)
+			}
+		}
+	}
+	if w.pattern == nil {
+		return string(buf)
+	}
+	return w.pattern.ReplaceAllString(string(buf), `"$1":"`+redacted+`"`)
+}
+
+func (w *wireDumper) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if w.fields[strings.ToLower(k)] {
+				t[k] = redactAll(e)
+			} else {
+				t[k] = w.redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = w.redactValue(e)
+		}
+	}
+	return v
+}
+
+// redactAll redact every value but keep the keys of the objects, e.g. the names of the template variables
+func redactAll(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = redactAll(e)
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redactAll(e)
+		}
+		return t
+	case nil:
+		return nil
+	}
+	return redacted
+}
+
+// readPrefix read at most limit bytes, more reports whether r may have more
+func readPrefix(r io.Reader, limit int) (buf []byte, more bool) {
+	buf = make([]byte, limit)
+	n, err := io.ReadFull(r, buf)
+	return buf[:n], err == nil
+}
+
+// prefixedBody a response body with the bytes read by the dump put back
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/auth"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWireDumpRedactsDebugLogs(t *testing.T) {
+	const mobile = "13800000000"
+	const token = "token-secret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{"mobile":"`+mobile+`","messageId":"m-1"}}`)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name         string
+		logBodyLimit int
+	}{
+		{"Whole", 0},
+		{"Truncated", 60},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			conf := newTestConfig(server.URL)
+			conf.Logger = logger.New(log.New(&out, "", 0), logger.Config{LogLevel: logger.Debug})
+			conf.LogBodyLimit = c.logBodyLimit
+			conf.WireDump = &config.WireDumpOptions{RedactHeaders: []string{"X-Token"}}
+			client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, conf)
+
+			var result struct {
+				Mobile string `json:"mobile"`
+			}
+			request := httpclient.ApplyCallOptions(
+				httpclient.NewDefaultAttainsRequest(context.Background(), nil).
+					WithMethod(http.MethodPost).WithPath("/sms/send").
+					WithBodyBytes([]byte(`{"mobile":"`+mobile+`","templateId":"t-1"}`)),
+				httpclient.CallHeader("X-Token", token))
+			if err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&result)); err != nil {
+				t.Fatal(err)
+			}
+			if result.Mobile != mobile {
+				t.Errorf("parsed mobile = %q, want %q", result.Mobile, mobile)
+			}
+
+			logs := out.String()
+			if !strings.Contains(logs, "Get raw response(") || !strings.Contains(logs, "Request header:") {
+				t.Fatalf("the debug lines are missing from the logs:\n%s", logs)
+			}
+			for _, secret := range []string{mobile, token} {
+				if strings.Contains(logs, secret) {
+					t.Errorf("the logs contain %q:\n%s", secret, logs)
+				}
+			}
+		})
+	}
+}
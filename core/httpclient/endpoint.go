@@ -0,0 +1,179 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultScheme return the scheme of the endpoints without one
+func defaultScheme(conf *config.AttainsConfig) string {
+	if conf.HttpsByDefault {
+		return metadata.RequestProtocolHttps
+	}
+	return metadata.RequestProtocolHttp
+}
+
+// parseEndpoint normalize the endpoint into an url with the scheme and host
+func parseEndpoint(endpoint, scheme string) *url.URL {
+	if !strings.HasPrefix(endpoint, metadata.RequestProtocolHttps+"://") && !strings.HasPrefix(endpoint, metadata.RequestProtocolHttp+"://") {
+		endpoint = scheme + "://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		u = &url.URL{Host: endpoint}
+	}
+	if u.Scheme != metadata.RequestProtocolHttps && u.Scheme != metadata.RequestProtocolHttp {
+		u.Scheme = scheme
+	}
+	if strings.LastIndex(u.Host, ":") > strings.LastIndex(u.Host, "]") {
+		u.Host = strings.TrimSuffix(u.Host, ":")
+	}
+	return u
+}
+
+type endpointState struct {
+	url            *url.URL
+	lastFailure    time.Time
+	unhealthyUntil time.Time
+}
+
+func (e *endpointState) healthy(now time.Time) bool {
+	return !now.Before(e.unhealthyUntil)
+}
+
+// endpointPool pick one of the configured endpoints for every attempt and track their health
+type endpointPool struct {
+	strategy  config.EndpointStrategy
+	cooldown  time.Duration
+	getLogger func() logger.Interface
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+	next      int
+}
+
+func newEndpointPool(conf *config.AttainsConfig, getLogger func() logger.Interface) *endpointPool {
+	if len(conf.Endpoints) == 0 {
+		return nil
+	}
+	cooldown := conf.EndpointCooldown
+	if cooldown <= 0 {
+		cooldown = metadata.DefaultEndpointCooldown
+	}
+	endpoints := make([]*endpointState, 0, len(conf.Endpoints))
+	for _, endpoint := range conf.Endpoints {
+		endpoints = append(endpoints, &endpointState{url: parseEndpoint(endpoint, defaultScheme(conf))})
+	}
+	return &endpointPool{
+		strategy:  conf.EndpointStrategy,
+		cooldown:  cooldown,
+		getLogger: getLogger,
+		endpoints: endpoints,
+	}
+}
+
+// pick select the endpoint for the next attempt by the strategy, the excluded endpoints are
+// only picked when all the others are unhealthy
+func (p *endpointPool) pick(excluded ...*endpointState) *endpointState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(excluded) > 0 {
+		available := make([]*endpointState, 0, len(p.endpoints))
+		for _, e := range p.endpoints {
+			if !containsEndpoint(excluded, e) {
+				available = append(available, e)
+			}
+		}
+		if e := p.pickFrom(available, time.Now()); e != nil {
+			return e
+		}
+	}
+	if e := p.pickFrom(p.endpoints, time.Now()); e != nil {
+		return e
+	}
+
+	// All the endpoints are cooling down, try the one recovering first
+	picked := p.endpoints[0]
+	for _, e := range p.endpoints[1:] {
+		if e.unhealthyUntil.Before(picked.unhealthyUntil) {
+			picked = e
+		}
+	}
+	return picked
+}
+
+// pickFrom select a healthy endpoint by the strategy, must hold the lock
+func (p *endpointPool) pickFrom(endpoints []*endpointState, now time.Time) *endpointState {
+	n := len(endpoints)
+	switch p.strategy {
+	case config.EndpointStrategyRoundRobin:
+		for i := 0; i < n; i++ {
+			e := endpoints[(p.next+i)%n]
+			if e.healthy(now) {
+				p.next = (p.next + i + 1) % n
+				return e
+			}
+		}
+	case config.EndpointStrategyLeastRecentFailure:
+		var picked *endpointState
+		for _, e := range endpoints {
+			if e.healthy(now) && (picked == nil || e.lastFailure.Before(picked.lastFailure)) {
+				picked = e
+			}
+		}
+		return picked
+	default:
+		for _, e := range endpoints {
+			if e.healthy(now) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func containsEndpoint(endpoints []*endpointState, e *endpointState) bool {
+	for _, item := range endpoints {
+		if item == e {
+			return true
+		}
+	}
+	return false
+}
+
+// report record the result of an attempt sent to the endpoint
+func (p *endpointPool) report(ctx context.Context, e *endpointState, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !failed {
+		e.unhealthyUntil = time.Time{}
+		return
+	}
+	now := time.Now()
+	e.lastFailure = now
+	e.unhealthyUntil = now.Add(p.cooldown)
+	p.getLogger().Warn(ctx, "Endpoint %s is marked unhealthy for %v", e.url.Host, p.cooldown)
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HedgingSettings configures the hedged requests, zero values are replaced by the defaults
+type HedgingSettings struct {
+	// Delay how long to wait for an attempt before sending the next one
+	Delay time.Duration
+	// MaxAttempts the concurrent attempts at most, including the first one
+	MaxAttempts int
+	// DifferentEndpoint send every hedged attempt to an endpoint not used by the others when possible
+	DifferentEndpoint bool
+}
+
+// WithHedging enable hedged requests for GET and HEAD, other methods are hedged only with an idempotency key,
+// the first successful attempt wins and the others are canceled. A request whose body is a seeker without
+// io.ReaderAt is never hedged, a body of WithBodyStream must support concurrent opens
+func WithHedging(settings HedgingSettings) ClientOption {
+	return func(d *DefaultAttainsHttpClient) {
+		if settings.Delay <= 0 {
+			settings.Delay = metadata.DefaultHedgingDelay
+		}
+		if settings.MaxAttempts <= 1 {
+			settings.MaxAttempts = metadata.DefaultHedgingMaxAttempts
+		}
+		d.hedging = &settings
+	}
+}
+
+type hedgeResult struct {
+	response *DefaultAttainsResponse
+	err      error
+}
+
+// usedEndpoints the endpoints already picked by the attempts of a hedged call
+type usedEndpoints struct {
+	mu        sync.Mutex
+	endpoints []*endpointState
+}
+
+func (u *usedEndpoints) list() []*endpointState {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]*endpointState(nil), u.endpoints...)
+}
+
+func (u *usedEndpoints) add(e *endpointState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.endpoints = append(u.endpoints, e)
+}
+
+type usedEndpointsKey struct{}
+
+func usedEndpointsFromContext(ctx context.Context) *usedEndpoints {
+	used, _ := ctx.Value(usedEndpointsKey{}).(*usedEndpoints)
+	return used
+}
+
+func isHedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	default:
+		return req.Header.Get(metadata.RequestKeyAttainsIdempotencyKey) != ""
+	}
+}
+
+// hedgeMiddleware send another attempt when the previous one is slow, the first success wins
+func (d *DefaultAttainsHttpClient) hedgeMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		resp, ok := response.(*DefaultAttainsResponse)
+		if d.hedging == nil || !ok || !isHedgeable(request.Build()) || !concurrentBody(request) {
+			return next(request, response)
+		}
+
+		ctx, cancel := context.WithCancel(request.GetContext())
+		// The losers are canceled when the call returns
+		defer cancel()
+		if d.hedging.DifferentEndpoint {
+			ctx = context.WithValue(ctx, usedEndpointsKey{}, &usedEndpoints{})
+		}
+
+		results := make(chan hedgeResult, d.hedging.MaxAttempts)
+		launch := func() {
+			branchRequest := extend(request).Clone(ctx)
+			branchResponse := resp.branch()
+			go func() {
+				results <- hedgeResult{response: branchResponse, err: next(branchRequest, branchResponse)}
+			}()
+		}
+
+		launch()
+		launched, finished := 1, 0
+		timer := time.NewTimer(d.hedging.Delay)
+		defer timer.Stop()
+		for {
+			select {
+			case result := <-results:
+				finished++
+				if result.err == nil {
+					resp.adopt(result.response, true)
+					return nil
+				}
+				// Report the failure when no other attempt is pending, the retry policy decides what's next
+				if finished == launched {
+					resp.adopt(result.response, false)
+					return result.err
+				}
+			case <-timer.C:
+				if launched < d.hedging.MaxAttempts {
+					d.GetLogger().Debug(request.GetContext(), "Send hedged attempt %d", launched)
+					launch()
+					launched++
+					timer.Reset(d.hedging.Delay)
+				}
+			}
+		}
+	}
+}
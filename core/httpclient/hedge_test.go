@@ -0,0 +1,208 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/auth"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestConfig the config of a client sending to the endpoint without retries
+func newTestConfig(endpoint string) *config.AttainsConfig {
+	return &config.AttainsConfig{
+		AttainsCustomConfig: config.AttainsCustomConfig{
+			Endpoint:    endpoint,
+			Credentials: &auth.AttainsCredentials{AccessKeyId: "ak", SecretAccessKey: "sk"},
+			SignOption:  &auth.SignOptions{ExpireSeconds: 1800},
+			Retry:       retry.NewAttainsNoRetryPolicy(),
+			Logger:      logger.Discard,
+		},
+	}
+}
+
+// seekerOnly hides the io.ReaderAt of the reader
+type seekerOnly struct {
+	io.ReadSeeker
+}
+
+// bodyServer answer the first request after the delay and check every body against its Content-MD5
+func bodyServer(t *testing.T, firstDelay time.Duration, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		sum := md5.Sum(body)
+		if got := base64.StdEncoding.EncodeToString(sum[:]); got != r.Header.Get("Content-Md5") {
+			t.Errorf("attempt %d: body %q does not match its Content-MD5", n, body)
+		}
+		if n == 1 {
+			select {
+			case <-time.After(firstDelay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{"attempt":`, n, `}}`)
+	}))
+}
+
+func TestHedgingSeekerBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	cases := []struct {
+		name      string
+		body      io.ReadSeeker
+		wantHedge bool
+	}{
+		{"ReaderAt", bytes.NewReader(payload), true},
+		{"SeekerOnly", seekerOnly{bytes.NewReader(payload)}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var hits int32
+			server := bodyServer(t, 200*time.Millisecond, &hits)
+			defer server.Close()
+			client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, newTestConfig(server.URL),
+				httpclient.WithHedging(httpclient.HedgingSettings{Delay: 10 * time.Millisecond, MaxAttempts: 3}))
+
+			request := httpclient.NewDefaultAttainsRequest(context.Background(), nil).(httpclient.AttainsExtendedRequest)
+			request.WithMethod(http.MethodPut)
+			request.WithPath("/upload")
+			request.WithBodySeeker(c.body)
+			httpclient.ApplyCallOptions(request, httpclient.CallIdempotencyKey("upload-1"))
+			if err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&struct{}{})); err != nil {
+				t.Fatal(err)
+			}
+			if hedged := atomic.LoadInt32(&hits) > 1; hedged != c.wantHedge {
+				t.Errorf("hedged = %v, want %v", hedged, c.wantHedge)
+			}
+		})
+	}
+}
+
+// hedgedServer hold the first attempt of every call until it is canceled, the next attempt answers at once
+func hedgedServer(t *testing.T, canceled *int32) *httptest.Server {
+	var seen sync.Map
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get("X-Attains-Request-Id")
+		if _, loaded := seen.LoadOrStore(requestId, true); !loaded {
+			select {
+			case <-r.Context().Done():
+				atomic.AddInt32(canceled, 1)
+			case <-time.After(time.Second):
+				t.Errorf("call %s: the slow attempt was not canceled", requestId)
+			}
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{"winner":"hedged"}}`)
+	}))
+}
+
+func TestHedgingWinnerAndLoser(t *testing.T) {
+	var canceled int32
+	server := hedgedServer(t, &canceled)
+	defer server.Close()
+	client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, newTestConfig(server.URL),
+		httpclient.WithHedging(httpclient.HedgingSettings{Delay: 20 * time.Millisecond, MaxAttempts: 2}))
+
+	const calls = 20
+	for i := 0; i < calls; i++ {
+		var result struct {
+			Winner string `json:"winner"`
+		}
+		start := time.Now()
+		request := httpclient.NewDefaultAttainsRequest(context.Background(), nil).WithPath("/balance")
+		if err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&result)); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if result.Winner != "hedged" {
+			t.Errorf("call %d: adopted result %+v, want the hedged attempt", i, result)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("call %d took %v, the hedged attempt did not win", i, elapsed)
+		}
+
+		// Let the loser of this call finish before the next call picks an idle connection
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&canceled) <= int32(i) && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if n := atomic.LoadInt32(&canceled); n != int32(i+1) {
+			t.Fatalf("call %d: canceled losers = %d, want %d", i, n, i+1)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// The canceled losers must not close the idle connection of the winners,
+	// so every call but the first starts on the connection of the previous winner
+	stats := client.(*httpclient.DefaultAttainsHttpClient).Stats()
+	if stats.ReusedConns < calls-1 {
+		t.Errorf("reused connections = %d of %d attempts, want at least %d", stats.ReusedConns, stats.Attempts, calls-1)
+	}
+}
+
+func TestHedgingMethods(t *testing.T) {
+	cases := []struct {
+		name           string
+		method         string
+		idempotencyKey string
+		wantHedge      bool
+	}{
+		{"GET", http.MethodGet, "", true},
+		{"POST without key", http.MethodPost, "", false},
+		{"POST with key", http.MethodPost, "send-1", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var hits int32
+			server := bodyServer(t, 200*time.Millisecond, &hits)
+			defer server.Close()
+			client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, newTestConfig(server.URL),
+				httpclient.WithHedging(httpclient.HedgingSettings{Delay: 10 * time.Millisecond, MaxAttempts: 2}))
+
+			request := httpclient.NewDefaultAttainsRequest(context.Background(), nil).
+				WithMethod(c.method).WithPath("/sms/send").WithBodyBytes([]byte(`{"templateId":"t-1"}`))
+			if c.idempotencyKey != "" {
+				httpclient.ApplyCallOptions(request, httpclient.CallIdempotencyKey(c.idempotencyKey))
+			}
+			if err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&struct{}{})); err != nil {
+				t.Fatal(err)
+			}
+			if hedged := atomic.LoadInt32(&hits) > 1; hedged != c.wantHedge {
+				t.Errorf("hedged = %v, want %v", hedged, c.wantHedge)
+			}
+		})
+	}
+}
@@ -18,7 +18,7 @@
 package httpclient
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"github.com/attains/attainscloud-sdk-go/core/auth"
 	"github.com/attains/attainscloud-sdk-go/core/config"
@@ -26,14 +26,9 @@ import (
 	"github.com/attains/attainscloud-sdk-go/core/logger"
 	"github.com/attains/attainscloud-sdk-go/core/metadata"
 	"github.com/attains/attainscloud-sdk-go/core/retry"
-	"github.com/attains/attainscloud-sdk-go/core/utils/strutil"
-	"github.com/attains/attainscloud-sdk-go/core/utils/timeutil"
-	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
-	"net/url"
-	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -44,32 +39,59 @@ type AttainsHttpClient interface {
 }
 
 type DefaultAttainsHttpClient struct {
+	// parsedResponses counts the responses for sampling the logged bodies, first for the 64-bit alignment
+	parsedResponses uint64
+
 	httpClient *http.Client
-	transport  *http.Transport
+	transport  http.RoundTripper
 	conf       *config.AttainsConfig
 	signer     auth.Signer
-	custom     bool
+
+	middlewares        []Middleware
+	attemptMiddlewares []Middleware
+	handler            Handler
+	endpoints          *endpointPool
+	hedging            *HedgingSettings
+	// initErr fails all the requests when the client is misconfigured
+	initErr error
+	// workers bounds the running asynchronous calls
+	workers chan struct{}
+	// dumper logs the attempts if the wire dump is enabled
+	dumper *wireDumper
+	stats  *clientStats
+	// listeners are notified of the lifecycle events of the calls
+	listeners []Listener
+	tracer    Tracer
 }
 
-func newAttainsHttpClient(signer auth.Signer, conf *config.AttainsConfig, httpClient *http.Client, transport *http.Transport, custom bool) AttainsHttpClient {
+func newAttainsHttpClient(signer auth.Signer, conf *config.AttainsConfig, httpClient *http.Client, transport http.RoundTripper, opts ...ClientOption) *DefaultAttainsHttpClient {
 	client := &DefaultAttainsHttpClient{
 		httpClient: httpClient,
 		transport:  transport,
 		conf:       conf,
 		signer:     signer,
-		custom:     custom,
 	}
 	client.httpClient.Transport = client.transport
+	client.endpoints = newEndpointPool(conf, client.GetLogger)
+	client.workers = newWorkers(conf.AsyncWorkers)
+	client.dumper = newWireDumper(conf.WireDump)
+	client.stats = newClientStats()
+	for _, opt := range opts {
+		opt(client)
+	}
+	client.handler = client.buildHandler()
 	return client
 }
 
-func NewCustomHttpClient(signer auth.Signer, conf *config.AttainsCustomConfig, httpClient *http.Client, transport *http.Transport) AttainsHttpClient {
+// NewCustomHttpClient create a client with the given http client and transport, the transport can be
+// any http.RoundTripper, e.g. a recorder.Recorder to record and replay the interactions in tests
+func NewCustomHttpClient(signer auth.Signer, conf *config.AttainsCustomConfig, httpClient *http.Client, transport http.RoundTripper, opts ...ClientOption) AttainsHttpClient {
 	return newAttainsHttpClient(signer, &config.AttainsConfig{
 		AttainsCustomConfig: *conf,
-	}, httpClient, transport, true)
+	}, httpClient, transport, opts...)
 }
 
-func NewAttainsHttpClient(signer auth.Signer, conf *config.AttainsConfig) AttainsHttpClient {
+func NewAttainsHttpClient(signer auth.Signer, conf *config.AttainsConfig, opts ...ClientOption) AttainsHttpClient {
 	httpClient := &http.Client{
 		Transport:     nil,
 		CheckRedirect: nil,
@@ -83,14 +105,16 @@ func NewAttainsHttpClient(signer auth.Signer, conf *config.AttainsConfig) Attain
 		}
 	}
 
+	tlsConfig, tlsErr := newTLSConfig(conf.TLS)
+	proxy, proxyErr := configProxy(conf)
 	transport := &http.Transport{
-		Proxy: nil,
+		Proxy: ProxyFromContext(proxy),
 		DialContext: (&net.Dialer{
 			Timeout:   metadata.DefaultDialTimeout,
 			KeepAlive: metadata.DefaultKeepAliveTimeout,
 		}).DialContext,
 		DialTLS:                nil,
-		TLSClientConfig:        nil,
+		TLSClientConfig:        tlsConfig,
 		TLSHandshakeTimeout:    10 * time.Second,
 		DisableKeepAlives:      false,
 		DisableCompression:     false,
@@ -108,10 +132,20 @@ func NewAttainsHttpClient(signer auth.Signer, conf *config.AttainsConfig) Attain
 		ForceAttemptHTTP2:      true,
 	}
 
-	return newAttainsHttpClient(signer, conf, httpClient, transport, false)
+	client := newAttainsHttpClient(signer, conf, httpClient, transport, opts...)
+	switch {
+	case tlsErr != nil:
+		client.initErr = errors.NewAttainsClientError(fmt.Sprintf("invalid tls options: %v", tlsErr))
+	case proxyErr != nil:
+		client.initErr = errors.NewAttainsClientError(fmt.Sprintf("invalid proxy config: %v", proxyErr))
+	}
+	if client.initErr != nil {
+		client.GetLogger().Error(context.Background(), "Create http client failed: %v", client.initErr)
+	}
+	return client
 }
 
-func NewDefaultAttainsClient(ak, sk string, endpoints string) AttainsHttpClient {
+func NewDefaultAttainsClient(ak, sk string, endpoints string, opts ...ClientOption) AttainsHttpClient {
 	conf := &config.AttainsConfig{
 		AttainsCustomConfig: config.AttainsCustomConfig{
 			Endpoint:  endpoints,
@@ -133,168 +167,51 @@ func NewDefaultAttainsClient(ak, sk string, endpoints string) AttainsHttpClient
 		RedirectDisabled:          false,
 	}
 	signer := &auth.AttainsV1Signer{}
-	return NewAttainsHttpClient(signer, conf)
+	return NewAttainsHttpClient(signer, conf, opts...)
 }
 
 func (d *DefaultAttainsHttpClient) SendRequest(request AttainsRequest, response AttainsResponse) error {
 	response.WithLogger(d.GetLogger())
-	d.GetLogger().Debug(request.GetContext(), "Start send request")
-	if !d.custom {
-		if proxyUrl := request.GetProxyUrl(); proxyUrl != nil {
-			d.transport.Proxy = func(request *http.Request) (*url.URL, error) {
-				return proxyUrl, nil
-			}
-		} else {
-			d.transport.Proxy = nil
-		}
-	}
-
-	req := request.Build()
-
-	endpoint := d.conf.Endpoint
-	if endpoint == "" {
-		endpoint = request.GetEndpoint()
-	}
-
-	if !strings.HasPrefix(endpoint, metadata.RequestProtocolHttps+"://") && !strings.HasPrefix(endpoint, metadata.RequestProtocolHttp+"://") {
-		endpoint = metadata.RequestProtocolHttp + "://" + endpoint
-	}
-	u, _ := url.Parse(endpoint)
-	if u.Scheme != metadata.RequestProtocolHttps && u.Scheme != metadata.RequestProtocolHttp {
-		u.Scheme = metadata.RequestProtocolHttp
+	if r, ok := response.(optionsResponse); ok {
+		r.WithOptions(d.responseOptions())
 	}
-	req.URL.Scheme = u.Scheme
-	req.URL.Host = u.Host
-
-	if strings.LastIndex(u.Host, ":") > strings.LastIndex(u.Host, "]") {
-		u.Host = strings.TrimSuffix(u.Host, ":")
-	}
-	req.Host = u.Host
-	req.Header.Set(metadata.RequestKeyHost, req.Host)
-
-	d.GetLogger().Debug(request.GetContext(), "Request url: %s", req.URL)
-	d.GetLogger().Debug(request.GetContext(), "Request host: %s", req.Host)
-
-	if contentType := req.Header.Get(metadata.RequestKeyContentType); contentType == "" {
-		req.Header.Set(metadata.RequestKeyContentType, metadata.DefaultContentType)
-	}
-	if userAgent := req.Header.Get(metadata.RequestKeyUserAgent); userAgent == "" {
-		if d.conf.UserAgent != "" {
-			req.Header.Set(metadata.RequestKeyUserAgent, d.conf.UserAgent)
-		} else {
-			req.Header.Set(metadata.RequestKeyUserAgent, config.DefaultUserAgent)
-		}
-	}
-	req.Header.Set(metadata.RequestKeyAttainsDate, timeutil.FormatISO8601Date(timeutil.NowUTCSeconds()))
-
-	requestId := request.GetRequestId()
-	if len(requestId) == 0 {
-		// Construct the request ID with UUID
-		requestId = strutil.NewRequestId()
-	}
-	req.Header.Set(metadata.RequestKeyAttainsRequestId, requestId)
-
-	d.GetLogger().Debug(request.GetContext(), "Request header: %v", req.Header)
-
-	if req.Body != nil {
-		_, contentMd5Exist := req.Header[metadata.RequestKeyContentMd5]
-		_, contentLengthExist := req.Header[metadata.RequestKeyContentLength]
-		if !contentMd5Exist || !contentLengthExist {
-			body, err := ioutil.ReadAll(req.Body)
-			defer req.Body.Close()
-			if err != nil {
-				return err
-			}
-			req.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-
-			buf := bytes.NewReader(body)
-			size := buf.Len()
-			if !contentMd5Exist {
-				contentMd5, err := strutil.CalculateContentMD5(buf, int64(size))
-				if err != nil {
-					return err
-				}
-				req.Header.Set(metadata.RequestKeyContentMd5, contentMd5)
-			}
-			if !contentLengthExist {
-				req.Header.Set(metadata.RequestKeyContentLength, fmt.Sprintf("%d", size))
-			}
-		}
-	}
-
-	signErr := d.signer.Sign(req, d.GetLogger(), d.conf.Credentials, d.conf.SignOption)
-	if signErr != nil {
-		return signErr
+	d.GetLogger().Debug(request.GetContext(), "Start send request")
+	if d.initErr != nil {
+		return d.initErr
 	}
+	return d.handler(extend(request), response)
+}
 
-	retries := 0
-	if req.Body != nil {
-		defer req.Body.Close() // Manually close the ReadCloser body for retry
+func (d *DefaultAttainsHttpClient) GetLogger() logger.Interface {
+	if d.conf.Logger == nil {
+		return logger.Default
 	}
+	return d.conf.Logger
+}
 
-	for {
-		d.GetLogger().Debug(request.GetContext(), "%dth try send request", retries)
-
-		var retryBuf bytes.Buffer
-		var teeReader io.Reader
-		if d.conf.Retry.ShouldRetry(nil, 0) && req.Body != nil {
-			teeReader = io.TeeReader(req.Body, &retryBuf)
-			req.Body = ioutil.NopCloser(teeReader)
-		}
-
-		for s, i := range req.Header {
-			fmt.Println(fmt.Sprintf("header(%s=%v)", s, i))
-		}
-
-		httpResponse, err := d.httpClient.Do(req)
-
-		if err != nil {
-			d.transport.CloseIdleConnections()
-			return err
-		}
-		if httpResponse.StatusCode >= 400 && (req.Method == http.MethodPost || req.Method == http.MethodPut) {
-			d.transport.CloseIdleConnections()
-		}
-		if err != nil {
-			if d.conf.Retry.ShouldRetry(err, retries) {
-				delayInMills := d.conf.Retry.GetDelayBeforeNextRetryInMillis(err, retries)
-				time.Sleep(delayInMills)
-			} else {
-				return errors.NewAttainsClientError(fmt.Sprintf("execute http request failed! Retried %d times, error: %v", retries, err))
-			}
-			retries++
-			if req.Body != nil {
-				_, _ = ioutil.ReadAll(teeReader)
-				req.Body = ioutil.NopCloser(&retryBuf)
-			}
-			continue
+// responseOptions read the response body within the configured limit, sampling the logged bodies
+func (d *DefaultAttainsHttpClient) responseOptions() ResponseOptions {
+	logBody := true
+	if sampling := d.conf.LogBodySampling; sampling > 1 {
+		logBody = atomic.AddUint64(&d.parsedResponses, 1)%uint64(sampling) == 1
+	}
+	options := ResponseOptions{
+		MaxBytes:     d.conf.MaxResponseBytes,
+		LogBody:      logBody,
+		LogBodyLimit: d.conf.LogBodyLimit,
+	}
+	// The logged body hides the same fields as the wire dump
+	if d.dumper != nil {
+		options.RedactBody = func(body []byte, truncated bool) string {
+			return d.redactSecrets(d.dumper.redactBody(body, truncated))
 		}
-		err = response.SetResponse(httpResponse).ParseResponse(request.GetContext())
-		if err != nil {
-			if serviceErr, ok := err.(*errors.AttainsServiceError); ok {
-				if d.conf.Retry.ShouldRetry(serviceErr, retries) {
-					delayInMills := d.conf.Retry.GetDelayBeforeNextRetryInMillis(serviceErr, retries)
-					time.Sleep(delayInMills)
-				} else {
-					return serviceErr
-				}
-				retries++
-				if req.Body != nil {
-					_, _ = ioutil.ReadAll(teeReader)
-					req.Body = ioutil.NopCloser(&retryBuf)
-				}
-				continue
-			}
-			return err
-		}
-
-		return nil
 	}
+	return options
 }
 
-func (d *DefaultAttainsHttpClient) GetLogger() logger.Interface {
-	if d.conf.Logger == nil {
-		return logger.Default
+func (d *DefaultAttainsHttpClient) getRetryPolicy() retry.AttainsRetryPolicy {
+	if d.conf.Retry == nil {
+		return retry.NewAttainsNoRetryPolicy()
 	}
-	return d.conf.Logger
+	return d.conf.Retry
 }
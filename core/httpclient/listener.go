@@ -0,0 +1,176 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Event the details of a lifecycle event of a call
+type Event struct {
+	Context   context.Context
+	RequestId string
+	Service   string
+	Method    string
+	Path      string
+	// Route the path template, e.g. /template/query/{templateId}, the path if the request has none
+	Route string
+	// Attempt the number of the attempt starting from 1, zero for OnRequestStart, the attempts made for OnFinish
+	Attempt int
+	// Latency the duration of the attempt for OnResponse, of the call for OnFinish
+	Latency time.Duration
+	// Delay the backoff before the next attempt for OnRetry
+	Delay time.Duration
+	// Err the error of the attempt for OnRetry and OnResponse, of the call for OnFinish
+	Err error
+	// Response the http response for OnResponse, the last one for OnFinish, nil if none was received
+	Response *http.Response
+}
+
+// Listener the callbacks of the lifecycle events of the calls, the nil ones are skipped, they run on the
+// goroutine of the call and should return quickly
+type Listener struct {
+	// OnRequestStart the call starts after its headers and body digest are prepared
+	OnRequestStart func(Event)
+	// OnAttempt an attempt starts, including the retries and the hedged ones
+	OnAttempt func(Event)
+	// OnSigned the attempt is signed
+	OnSigned func(Event)
+	// OnResponse the attempt got a response or failed to send
+	OnResponse func(Event)
+	// OnRetry the attempt failed and another one is sent after the delay
+	OnRetry func(Event)
+	// OnFinish the call returns
+	OnFinish func(Event)
+}
+
+// WithListener add a listener of the lifecycle events, e.g. to feed the metrics or audit systems
+func WithListener(listener Listener) ClientOption {
+	return func(d *DefaultAttainsHttpClient) {
+		d.listeners = append(d.listeners, listener)
+	}
+}
+
+type eventKind int
+
+const (
+	eventRequestStart eventKind = iota
+	eventAttempt
+	eventSigned
+	eventResponse
+	eventRetry
+	eventFinish
+)
+
+func (l *Listener) callback(kind eventKind) func(Event) {
+	switch kind {
+	case eventRequestStart:
+		return l.OnRequestStart
+	case eventAttempt:
+		return l.OnAttempt
+	case eventSigned:
+		return l.OnSigned
+	case eventResponse:
+		return l.OnResponse
+	case eventRetry:
+		return l.OnRetry
+	case eventFinish:
+		return l.OnFinish
+	}
+	return nil
+}
+
+type callAttemptsKey struct{}
+
+type attemptKey struct{}
+
+// notify call the listeners of the event, fill sets the fields specific to the event
+func (d *DefaultAttainsHttpClient) notify(kind eventKind, request AttainsRequest, fill func(*Event)) {
+	if len(d.listeners) == 0 {
+		return
+	}
+	ctx := request.GetContext()
+	req := request.Build()
+	e := Event{
+		Context:   ctx,
+		RequestId: req.Header.Get(metadata.RequestKeyAttainsRequestId),
+		Service:   extend(request).GetService(),
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Route:     extend(request).GetRoute(),
+	}
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		e.Attempt = attempt
+	}
+	if fill != nil {
+		fill(&e)
+	}
+	for i := range d.listeners {
+		if callback := d.listeners[i].callback(kind); callback != nil {
+			callback(e)
+		}
+	}
+}
+
+// withContext bind the request to ctx, the body of the request replaced by the clone is closed
+func withContext(request AttainsRequest, ctx context.Context) AttainsRequest {
+	body := request.Build().Body
+	clone := extend(request).Clone(ctx)
+	if body != nil && body != clone.Build().Body {
+		_ = body.Close()
+	}
+	return clone
+}
+
+// listenerMiddleware notify the start and the end of the call
+func (d *DefaultAttainsHttpClient) listenerMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		if len(d.listeners) == 0 {
+			return next(request, response)
+		}
+		attempts := new(int32)
+		request = withContext(request, context.WithValue(request.GetContext(), callAttemptsKey{}, attempts))
+		start := time.Now()
+		d.notify(eventRequestStart, request, nil)
+		err := next(request, response)
+		d.notify(eventFinish, request, func(e *Event) {
+			e.Attempt = int(atomic.LoadInt32(attempts))
+			e.Latency = time.Since(start)
+			e.Err = err
+			e.Response = response.GetResponse()
+		})
+		return err
+	}
+}
+
+// attemptListenerMiddleware number the attempts of the call and notify their start
+func (d *DefaultAttainsHttpClient) attemptListenerMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		attempts, ok := request.GetContext().Value(callAttemptsKey{}).(*int32)
+		if !ok {
+			return next(request, response)
+		}
+		attempt := int(atomic.AddInt32(attempts, 1))
+		request = withContext(request, context.WithValue(request.GetContext(), attemptKey{}, attempt))
+		d.notify(eventAttempt, request, nil)
+		return next(request, response)
+	}
+}
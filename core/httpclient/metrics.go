@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/metrics"
+	"net/http"
+	"strconv"
+)
+
+// WithMetrics collect the metrics of the calls, e.g. into a metrics.Registry served to Prometheus
+func WithMetrics(collector metrics.Collector) ClientOption {
+	return WithListener(Listener{
+		OnRequestStart: func(e Event) {
+			collector.AddGauge(metrics.RequestsInFlight, metrics.Labels{metrics.LabelService: e.Service}, 1)
+		},
+		OnRetry: func(e Event) {
+			collector.AddCounter(metrics.RetriesTotal, callLabels(e), 1)
+		},
+		OnFinish: func(e Event) {
+			collector.AddGauge(metrics.RequestsInFlight, metrics.Labels{metrics.LabelService: e.Service}, -1)
+			labels := callLabels(e)
+			collector.ObserveHistogram(metrics.RequestDuration, labels, e.Latency.Seconds())
+			collector.ObserveHistogram(metrics.AttemptsPerCall, labels, float64(e.Attempt))
+			status := ""
+			if e.Response != nil {
+				status = strconv.Itoa(e.Response.StatusCode)
+			}
+			collector.AddCounter(metrics.RequestsTotal, metrics.Labels{
+				metrics.LabelService: e.Service,
+				metrics.LabelPath:    e.Route,
+				metrics.LabelMethod:  e.Method,
+				metrics.LabelStatus:  status,
+				metrics.LabelCode:    resultCode(e.Err),
+			}, 1)
+		},
+	})
+}
+
+func callLabels(e Event) metrics.Labels {
+	return metrics.Labels{
+		metrics.LabelService: e.Service,
+		metrics.LabelPath:    e.Route,
+		metrics.LabelMethod:  e.Method,
+	}
+}
+
+// resultCode the code of the service error, or the kind of the failure, 200 for a success
+func resultCode(err error) string {
+	if err == nil {
+		return strconv.Itoa(http.StatusOK)
+	}
+	if e, ok := err.(*errors.AttainsServiceError); ok {
+		return strconv.FormatInt(e.Code(), 10)
+	}
+	return failureKind(err)
+}
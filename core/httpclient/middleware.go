@@ -0,0 +1,485 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"github.com/attains/attainscloud-sdk-go/core/ratelimit"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"github.com/attains/attainscloud-sdk-go/core/utils/strutil"
+	"github.com/attains/attainscloud-sdk-go/core/utils/timeutil"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Handler handles an AttainsRequest and fills the AttainsResponse
+type Handler func(AttainsRequest, AttainsResponse) error
+
+// Middleware wraps a Handler with an additional step, it should call next to continue the chain
+type Middleware func(next Handler) Handler
+
+// Chain builds a Handler from the middlewares, the first middleware is the outermost one
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// ClientOption configures a DefaultAttainsHttpClient
+type ClientOption func(*DefaultAttainsHttpClient)
+
+// WithMiddleware appends middlewares wrapping the whole call, they run once per call before signing
+func WithMiddleware(middlewares ...Middleware) ClientOption {
+	return func(d *DefaultAttainsHttpClient) {
+		d.middlewares = append(d.middlewares, middlewares...)
+	}
+}
+
+// WithAttemptMiddleware appends middlewares wrapping every attempt, they run inside the retry loop
+func WithAttemptMiddleware(middlewares ...Middleware) ClientOption {
+	return func(d *DefaultAttainsHttpClient) {
+		d.attemptMiddlewares = append(d.attemptMiddlewares, middlewares...)
+	}
+}
+
+// buildHandler assemble the middleware chain:
+// stats -> timeout -> custom middlewares -> response metadata -> prepare -> listener -> trace -> retry ->
+// hedge -> attempt listener -> attempt trace -> rate limit -> endpoint -> sign ->
+// custom attempt middlewares -> parse -> send
+func (d *DefaultAttainsHttpClient) buildHandler() Handler {
+	middlewares := make([]Middleware, 0, len(d.middlewares)+len(d.attemptMiddlewares)+14)
+	middlewares = append(middlewares, d.statsMiddleware, d.timeoutMiddleware)
+	middlewares = append(middlewares, d.middlewares...)
+	middlewares = append(middlewares, d.responseMetadataMiddleware, d.prepareMiddleware, d.listenerMiddleware,
+		d.traceMiddleware, d.retryMiddleware, d.hedgeMiddleware, d.attemptListenerMiddleware,
+		d.attemptTraceMiddleware, d.rateLimitMiddleware, d.endpointMiddleware, d.signMiddleware)
+	middlewares = append(middlewares, d.attemptMiddlewares...)
+	middlewares = append(middlewares, d.parseMiddleware)
+	return Chain(d.send, middlewares...)
+}
+
+// timeoutMiddleware bind the call to a context with the timeout of the request
+func (d *DefaultAttainsHttpClient) timeoutMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		if timeout := extend(request).GetTimeout(); timeout > 0 {
+			ctx, cancel := context.WithTimeout(request.GetContext(), timeout)
+			defer cancel()
+			request = extend(request).Clone(ctx)
+		}
+		return next(request, response)
+	}
+}
+
+// responseMetadataMiddleware fill the ResponseMetadata of the request when the call returns
+func (d *DefaultAttainsHttpClient) responseMetadataMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		md := extend(request).GetResponseMetadata()
+		if md == nil {
+			return next(request, response)
+		}
+		*md = ResponseMetadata{}
+		start := time.Now()
+		err := next(request, response)
+		md.Elapsed = time.Since(start)
+		md.Attempts = int(atomic.LoadInt32(&md.attempts))
+		md.RequestId = request.Build().Header.Get(metadata.RequestKeyAttainsRequestId)
+		if httpResponse := response.GetResponse(); httpResponse != nil {
+			md.StatusCode = httpResponse.StatusCode
+			md.Header = httpResponse.Header
+			md.ServerRequestId = httpResponse.Header.Get(metadata.RequestKeyAttainsRequestId)
+		}
+		return err
+	}
+}
+
+// prepareMiddleware set the common headers and body digest
+func (d *DefaultAttainsHttpClient) prepareMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		req := request.Build()
+
+		if contentType := req.Header.Get(metadata.RequestKeyContentType); contentType == "" {
+			req.Header.Set(metadata.RequestKeyContentType, metadata.DefaultContentType)
+		}
+		if userAgent := req.Header.Get(metadata.RequestKeyUserAgent); userAgent == "" {
+			if d.conf.UserAgent != "" {
+				req.Header.Set(metadata.RequestKeyUserAgent, d.conf.UserAgent)
+			} else {
+				req.Header.Set(metadata.RequestKeyUserAgent, config.DefaultUserAgent)
+			}
+		}
+		requestId := request.GetRequestId()
+		if len(requestId) == 0 {
+			// Construct the request ID with UUID
+			requestId = strutil.NewRequestId()
+		}
+		req.Header.Set(metadata.RequestKeyAttainsRequestId, requestId)
+		// The key is generated once per call so that every attempt carries the same one
+		if d.conf.IdempotencyKeys && !isIdempotent(req.Method) && req.Header.Get(metadata.RequestKeyAttainsIdempotencyKey) == "" {
+			req.Header.Set(metadata.RequestKeyAttainsIdempotencyKey, strutil.NewUUID())
+		}
+
+		if d.dumper != nil {
+			d.GetLogger().Debug(request.GetContext(), "Request header: %v", d.dumper.redactHeader(req.Header))
+		} else {
+			d.GetLogger().Debug(request.GetContext(), "Request header: %v", req.Header)
+		}
+
+		if req.Body != nil {
+			// A body given as io.ReadCloser is buffered once, the others are read again by GetBody
+			if req.GetBody == nil {
+				body, err := ioutil.ReadAll(req.Body)
+				_ = req.Body.Close()
+				if err != nil {
+					return err
+				}
+				setBodyBytes(req, body)
+			}
+			if d.shouldCompress(req, req.ContentLength) {
+				body, err := ioutil.ReadAll(req.Body)
+				if err != nil {
+					return err
+				}
+				if body, err = compressBody(body, d.conf.Compression.Level); err != nil {
+					return err
+				}
+				setBodyBytes(req, body)
+				req.Header.Set(metadata.RequestKeyContentEncoding, metadata.ContentEncodingGzip)
+				d.GetLogger().Debug(request.GetContext(), "Request body is compressed to %d bytes", len(body))
+			}
+
+			size := req.ContentLength
+			if _, exist := req.Header[metadata.RequestKeyContentMd5]; !exist {
+				body, err := req.GetBody()
+				if err != nil {
+					return err
+				}
+				contentMd5, err := strutil.CalculateContentMD5(body, size)
+				_ = body.Close()
+				if err != nil {
+					return err
+				}
+				req.Header.Set(metadata.RequestKeyContentMd5, contentMd5)
+			}
+			if _, exist := req.Header[metadata.RequestKeyContentLength]; !exist {
+				req.Header.Set(metadata.RequestKeyContentLength, fmt.Sprintf("%d", size))
+			}
+
+			// The first attempt reads the body from the start as the retries do
+			_ = req.Body.Close()
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			req.Body = body
+		}
+
+		return next(request, response)
+	}
+}
+
+// setBodyBytes keep the body in memory replayable for the retries
+func setBodyBytes(req *http.Request, body []byte) {
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// endpointMiddleware resolve the endpoint of every attempt, failing over between the configured endpoints
+func (d *DefaultAttainsHttpClient) endpointMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		ctx := request.GetContext()
+		if override := extend(request).GetEndpointOverride(); override != "" {
+			return d.sendToEndpoint(next, request, response, parseEndpoint(override, defaultScheme(d.conf)))
+		}
+		if d.endpoints == nil {
+			endpoint := d.conf.Endpoint
+			if endpoint == "" {
+				endpoint = request.GetEndpoint()
+			}
+			return d.sendToEndpoint(next, request, response, parseEndpoint(endpoint, defaultScheme(d.conf)))
+		}
+
+		// An endpoint whose circuit is open has not been sent anything, the attempt goes on with another one
+		var tried []*endpointState
+		var lastErr error
+		for {
+			var selected *endpointState
+			if used := usedEndpointsFromContext(ctx); used != nil {
+				selected = d.endpoints.pick(append(used.list(), tried...)...)
+				used.add(selected)
+			} else {
+				selected = d.endpoints.pick(tried...)
+			}
+			if containsEndpoint(tried, selected) {
+				return lastErr
+			}
+			tried = append(tried, selected)
+
+			err := d.sendToEndpoint(next, request, response, selected.url)
+			if ctx.Err() != nil || d.isDryRun(request) {
+				return err
+			}
+			circuitOpen := errors.IsClientErrorKind(err, errors.ClientErrorKindCircuitOpen)
+			d.endpoints.report(ctx, selected, circuitOpen || (err != nil && defaultIsFailure(err)))
+			if !circuitOpen {
+				return err
+			}
+			d.GetLogger().Debug(ctx, "Circuit breaker is open for %s, try another endpoint", selected.url.Host)
+			lastErr = err
+		}
+	}
+}
+
+// sendToEndpoint point the request to the endpoint and continue the chain
+func (d *DefaultAttainsHttpClient) sendToEndpoint(next Handler, request AttainsRequest, response AttainsResponse, u *url.URL) error {
+	ctx := request.GetContext()
+	req := request.Build()
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.Host = u.Host
+	req.Header.Set(metadata.RequestKeyHost, req.Host)
+
+	d.GetLogger().Debug(ctx, "Request url: %s", req.URL)
+	d.GetLogger().Debug(ctx, "Request host: %s", req.Host)
+
+	return next(request, response)
+}
+
+// signMiddleware stamp the date and sign the request of every attempt with the configured signer and credentials
+func (d *DefaultAttainsHttpClient) signMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		req := request.Build()
+		req.Header.Set(metadata.RequestKeyAttainsDate, timeutil.FormatISO8601Date(timeutil.NowUTCSeconds()))
+		if err := d.signer.Sign(req, d.GetLogger(), d.conf.Credentials, d.conf.SignOption); err != nil {
+			return err
+		}
+		d.notify(eventSigned, request, nil)
+		return next(request, response)
+	}
+}
+
+// callRetriesKey the context key of the *int32 the retry loop sets to the retries of the call so far
+type callRetriesKey struct{}
+
+// retryMiddleware retry the rest of the chain according to the retry policy
+func (d *DefaultAttainsHttpClient) retryMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		ctx := request.GetContext()
+		req := request.Build()
+		policy := extend(request).GetRetryPolicy()
+		if policy == nil {
+			policy = d.getRetryPolicy()
+		}
+		// A non-idempotent call may be applied twice unless the server can dedupe it by the key
+		retryable := isIdempotent(req.Method) || req.Header.Get(metadata.RequestKeyAttainsIdempotencyKey) != ""
+		retries := 0
+		var totalDelay time.Duration
+		for {
+			// Never start an attempt once the caller has given up
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return errors.NewAttainsCanceledError(retries, ctxErr, nil)
+			}
+			d.GetLogger().Debug(ctx, "%dth try send request", retries)
+
+			if retries > 0 && req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return err
+				}
+				req.Body = body
+			}
+
+			response.SetResponse(nil)
+			err := next(request, response)
+			if err == nil {
+				return nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return errors.NewAttainsCanceledError(retries+1, ctxErr, err)
+			}
+
+			state := &retry.RetryState{
+				Attempts:   retries,
+				Response:   response.GetResponse(),
+				TotalDelay: totalDelay,
+			}
+			switch realErr := err.(type) {
+			case *errors.AttainsServiceError:
+				if !retryable || !retry.ShouldRetry(policy, realErr, state) {
+					return realErr
+				}
+			case net.Error:
+				if !(retryable || notSent(realErr)) || !retry.ShouldRetry(policy, realErr, state) {
+					return errors.NewAttainsClientErrorWithKind(errors.ClientErrorKindNetwork,
+						fmt.Sprintf("execute http request failed! Retried %d times, error: %v", retries, err))
+				}
+			case *errors.AttainsClientError:
+				if !notSent(realErr) || !retry.ShouldRetry(policy, realErr, state) {
+					return realErr
+				}
+			default:
+				return err
+			}
+
+			delayInMills := retry.GetDelayBeforeNextRetry(policy, err, state)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delayInMills {
+				d.GetLogger().Debug(ctx, "Deadline is shorter than the retry delay %v, give up", delayInMills)
+				return errors.NewAttainsCanceledError(retries+1, context.DeadlineExceeded, err)
+			}
+			d.notify(eventRetry, request, func(e *Event) {
+				e.Attempt = retries + 1
+				e.Delay = delayInMills
+				e.Err = err
+			})
+			if ctxErr := sleepWithContext(ctx, delayInMills); ctxErr != nil {
+				return errors.NewAttainsCanceledError(retries+1, ctxErr, err)
+			}
+			totalDelay += delayInMills
+			retries++
+			atomic.AddUint64(&d.stats.retries, 1)
+			if counter, ok := ctx.Value(callRetriesKey{}).(*int32); ok {
+				atomic.StoreInt32(counter, int32(retries))
+			}
+		}
+	}
+}
+
+// notSent check whether the attempt failed before the request reached the server,
+// such an attempt can be retried whatever the method is
+func notSent(err error) bool {
+	if errors.IsClientErrorKind(err, errors.ClientErrorKindCircuitOpen) ||
+		errors.IsClientErrorKind(err, errors.ClientErrorKindRateLimited) {
+		return true
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// isIdempotent check whether the method can be applied more than once with the same effect
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleepWithContext wait for the delay, return the context error if the context is done before
+func sleepWithContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitMiddleware wait for the tokens of the path and the client before every attempt
+func (d *DefaultAttainsHttpClient) rateLimitMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		if d.isDryRun(request) {
+			return next(request, response)
+		}
+		ctx := request.GetContext()
+		for _, limiter := range []*ratelimit.Limiter{d.conf.PathRateLimiters.Get(request.Build().URL.Path), d.conf.RateLimiter} {
+			if err := limiter.Wait(ctx); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				return errors.NewAttainsClientErrorWithKind(errors.ClientErrorKindRateLimited,
+					fmt.Sprintf("wait for rate limit of %s failed: %v", request.Build().URL.Path, err))
+			}
+		}
+		return next(request, response)
+	}
+}
+
+// parseMiddleware parse the http response into the AttainsResponse
+func (d *DefaultAttainsHttpClient) parseMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		if err := next(request, response); err != nil {
+			return err
+		}
+		return response.ParseResponse(request.GetContext())
+	}
+}
+
+// send execute the http request, it is the innermost Handler of the chain
+func (d *DefaultAttainsHttpClient) send(request AttainsRequest, response AttainsResponse) error {
+	req := request.Build()
+	if proxyUrl := request.GetProxyUrl(); proxyUrl != nil {
+		req = req.WithContext(withProxyUrl(req.Context(), proxyUrl))
+	}
+	if d.dumper != nil {
+		d.dumpRequest(req)
+	}
+	if d.isDryRun(request) {
+		return d.dryRun(req)
+	}
+
+	if md := extend(request).GetResponseMetadata(); md != nil {
+		atomic.AddInt32(&md.attempts, 1)
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), d.stats.trace))
+	start := time.Now()
+	httpResponse, err := d.httpClient.Do(req)
+	elapsed := time.Since(start)
+	d.stats.observe(req.URL.Host, elapsed)
+	d.notify(eventResponse, request, func(e *Event) {
+		e.Latency = elapsed
+		e.Err = err
+		e.Response = httpResponse
+	})
+	if d.dumper != nil {
+		d.dumpResponse(req, httpResponse, err, elapsed)
+	}
+	if err != nil {
+		// An attempt canceled by the caller or by hedging says nothing about the idle connections
+		if req.Context().Err() == nil {
+			d.httpClient.CloseIdleConnections()
+		}
+		return err
+	}
+	if httpResponse.StatusCode >= 400 && (req.Method == http.MethodPost || req.Method == http.MethodPut) {
+		d.httpClient.CloseIdleConnections()
+	}
+	response.SetResponse(httpResponse)
+	return nil
+}
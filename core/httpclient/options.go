@@ -0,0 +1,112 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CallOption customizes a single call of a service client
+type CallOption func(AttainsRequest)
+
+// extendedOption apply the option to the requests with the call settings, the other requests ignore it
+func extendedOption(apply func(AttainsExtendedRequest)) CallOption {
+	return func(request AttainsRequest) {
+		if extended, ok := request.(AttainsExtendedRequest); ok {
+			apply(extended)
+		}
+	}
+}
+
+// ApplyCallOptions apply the options to the request in order
+func ApplyCallOptions(request AttainsRequest, opts ...CallOption) AttainsRequest {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(request)
+		}
+	}
+	return request
+}
+
+// CallTimeout limit the whole call including the retries
+func CallTimeout(timeout time.Duration) CallOption {
+	return extendedOption(func(request AttainsExtendedRequest) {
+		request.WithTimeout(timeout)
+	})
+}
+
+// CallHeader add a header to the request
+func CallHeader(key, value string) CallOption {
+	return func(request AttainsRequest) {
+		request.WithHeader(http.Header{key: []string{value}})
+	}
+}
+
+// CallRequestId send the request with the request id instead of a generated one
+func CallRequestId(requestId string) CallOption {
+	return func(request AttainsRequest) {
+		request.WithRequestId(requestId)
+	}
+}
+
+// CallIdempotencyKey send the request with the idempotency key, e.g. the id of a job so that its own retries
+// are deduped too, a non-idempotent call is retried only with a key
+func CallIdempotencyKey(key string) CallOption {
+	return func(request AttainsRequest) {
+		request.WithHeader(http.Header{metadata.RequestKeyAttainsIdempotencyKey: []string{key}})
+	}
+}
+
+// CallRetryPolicy override the retry policy of the client
+func CallRetryPolicy(policy retry.AttainsRetryPolicy) CallOption {
+	return extendedOption(func(request AttainsExtendedRequest) {
+		request.WithRetryPolicy(policy)
+	})
+}
+
+// CallEndpoint override the endpoints of the client
+func CallEndpoint(endpoint string) CallOption {
+	return extendedOption(func(request AttainsExtendedRequest) {
+		request.WithEndpointOverride(endpoint)
+	})
+}
+
+// CallProxy send the request through the proxy, see ProxyFromContext for the clients with custom transport
+func CallProxy(proxyUrl *url.URL) CallOption {
+	return func(request AttainsRequest) {
+		request.WithProxyUrl(proxyUrl)
+	}
+}
+
+// CallResponseMetadata fill md with the metadata of the response when the call returns, even if it fails
+func CallResponseMetadata(md *ResponseMetadata) CallOption {
+	return extendedOption(func(request AttainsExtendedRequest) {
+		request.WithResponseMetadata(md)
+	})
+}
+
+// CallDryRun build and sign the request without sending it, the call fails with a DryRunError holding the request
+func CallDryRun() CallOption {
+	return extendedOption(func(request AttainsExtendedRequest) {
+		request.WithDryRun(true)
+	})
+}
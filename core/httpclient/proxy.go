@@ -0,0 +1,94 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	proxySchemeSocks5 = "socks5"
+)
+
+type proxyUrlKey struct{}
+
+// withProxyUrl bind the proxy of a single request to its context
+func withProxyUrl(ctx context.Context, proxyUrl *url.URL) context.Context {
+	return context.WithValue(ctx, proxyUrlKey{}, proxyUrl)
+}
+
+// ProxyFromContext return a Proxy func of http.Transport choosing the proxy set by AttainsRequest.WithProxyUrl,
+// the requests without one use the fallback, nil fallback means no proxy. The transport of the client created by
+// NewAttainsHttpClient uses it already, custom transports can use it to honor the proxy of every request.
+func ProxyFromContext(fallback func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if proxyUrl, ok := req.Context().Value(proxyUrlKey{}).(*url.URL); ok && proxyUrl != nil {
+			return proxyUrl, nil
+		}
+		if fallback != nil {
+			return fallback(req)
+		}
+		return nil, nil
+	}
+}
+
+// parseProxyUrl parse the proxy url of the config, the scheme defaults to http
+func parseProxyUrl(rawUrl string) (*url.URL, error) {
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = metadata.RequestProtocolHttp + "://" + rawUrl
+	}
+	proxyUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		// The url may hold the credentials of the proxy, only the cause is reported
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy url: %v", err)
+	}
+	switch proxyUrl.Scheme {
+	case metadata.RequestProtocolHttp, metadata.RequestProtocolHttps, proxySchemeSocks5:
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+	}
+	if proxyUrl.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url: missing host")
+	}
+	return proxyUrl, nil
+}
+
+// configProxy return the proxy func of the config, nil if no proxy is configured, the proxy url is
+// checked here so that a misconfigured client fails every request instead of retrying them
+func configProxy(conf *config.AttainsConfig) (func(*http.Request) (*url.URL, error), error) {
+	if conf.ProxyUrl != "" {
+		proxyUrl, err := parseProxyUrl(conf.ProxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		return http.ProxyURL(proxyUrl), nil
+	}
+	if conf.ProxyFromEnvironment {
+		return http.ProxyFromEnvironment, nil
+	}
+	return nil, nil
+}
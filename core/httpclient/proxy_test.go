@@ -0,0 +1,96 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/auth"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newProxy start a fake forward proxy answering the requests itself and counting them
+func newProxy(t *testing.T, name string, hits *int32) (*httptest.Server, *url.URL) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if got := r.Header.Get("X-Proxy"); got != name {
+			t.Errorf("proxy %s received the request for proxy %s", name, got)
+		}
+		if r.URL.Host != "sms.example.invalid" {
+			t.Errorf("proxy %s received the request for host %q", name, r.URL.Host)
+		}
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{"messageId":"m-1"}}`)
+	}))
+	proxyUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, proxyUrl
+}
+
+func TestCallProxyConcurrent(t *testing.T) {
+	var hitsA, hitsB int32
+	proxyA, urlA := newProxy(t, "a", &hitsA)
+	defer proxyA.Close()
+	proxyB, urlB := newProxy(t, "b", &hitsB)
+	defer proxyB.Close()
+
+	client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, &config.AttainsConfig{
+		AttainsCustomConfig: config.AttainsCustomConfig{
+			Endpoint:    "sms.example.invalid",
+			Credentials: &auth.AttainsCredentials{AccessKeyId: "ak", SecretAccessKey: "sk"},
+			SignOption:  &auth.SignOptions{ExpireSeconds: 1800},
+			Retry:       retry.NewAttainsNoRetryPolicy(),
+			Logger:      logger.Discard,
+		},
+	})
+
+	const calls = 40
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name, proxyUrl := "a", urlA
+			if i%2 == 1 {
+				name, proxyUrl = "b", urlB
+			}
+			request := httpclient.ApplyCallOptions(
+				httpclient.NewDefaultAttainsRequest(context.Background(), nil).
+					WithMethod(http.MethodPost).WithPath("/sms/send").WithBodyBytes([]byte(`{"templateId":"t-1"}`)),
+				httpclient.CallProxy(proxyUrl), httpclient.CallHeader("X-Proxy", name))
+			if err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&struct{}{})); err != nil {
+				t.Errorf("call %d through proxy %s: %v", i, name, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if a, b := atomic.LoadInt32(&hitsA), atomic.LoadInt32(&hitsB); a != calls/2 || b != calls/2 {
+		t.Errorf("proxy hits = %d/%d, want %d/%d", a, b, calls/2, calls/2)
+	}
+}
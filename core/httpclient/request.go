@@ -20,11 +20,16 @@ package httpclient
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type AttainsRequest interface {
@@ -45,11 +50,55 @@ type AttainsRequest interface {
 	Build() *http.Request
 }
 
+// AttainsExtendedRequest the settings of a single call beyond AttainsRequest, implemented by DefaultAttainsRequest.
+// A request implementing only AttainsRequest is copied into a DefaultAttainsRequest when it is sent, so that it
+// gets the features of the client with the default settings
+type AttainsExtendedRequest interface {
+	AttainsRequest
+	// WithBodySeeker stream the body from the current offset of a seekable reader without buffering it
+	WithBodySeeker(io.ReadSeeker) AttainsRequest
+	// WithBodyStream stream the body opened by getBody for every attempt, the digest is computed by
+	// reading the body once more if contentMd5 is empty, getBody is called concurrently by the hedged attempts
+	WithBodyStream(getBody func() (io.ReadCloser, error), size int64, contentMd5 string) AttainsRequest
+	// WithEndpointOverride set the endpoint taking precedence over the endpoints of the client
+	WithEndpointOverride(string) AttainsRequest
+	GetEndpointOverride() string
+	// WithTimeout limit the whole call including the retries
+	WithTimeout(time.Duration) AttainsRequest
+	GetTimeout() time.Duration
+	// WithRetryPolicy set the retry policy taking precedence over the policy of the client
+	WithRetryPolicy(retry.AttainsRetryPolicy) AttainsRequest
+	GetRetryPolicy() retry.AttainsRetryPolicy
+	// WithResponseMetadata set the metadata filled when the call returns
+	WithResponseMetadata(*ResponseMetadata) AttainsRequest
+	GetResponseMetadata() *ResponseMetadata
+	// WithService set the name of the service for the metrics, e.g. sms
+	WithService(string) AttainsRequest
+	GetService() string
+	// WithRoute set the path template for the metrics, e.g. /template/query/{templateId}, the path is used if empty
+	WithRoute(string) AttainsRequest
+	GetRoute() string
+	// WithDryRun build and sign the request without sending it, see DryRunError
+	WithDryRun(bool) AttainsRequest
+	IsDryRun() bool
+	// Clone return a copy of the request bound to the context, with its own headers and body
+	Clone(context.Context) AttainsExtendedRequest
+}
+
 type DefaultAttainsRequest struct {
-	request   *http.Request
-	proxyURL  *url.URL
-	endpoint  string
-	requestId string
+	request          *http.Request
+	proxyURL         *url.URL
+	endpoint         string
+	endpointOverride string
+	requestId        string
+	timeout          time.Duration
+	retryPolicy      retry.AttainsRetryPolicy
+	responseMetadata *ResponseMetadata
+	dryRun           bool
+	service          string
+	route            string
+	// sequentialBody the body is a shared seeker, its attempts must not run concurrently
+	sequentialBody bool
 }
 
 func NewDefaultAttainsRequest(ctx context.Context, r *http.Request) AttainsRequest {
@@ -130,14 +179,78 @@ func (d *DefaultAttainsRequest) GetRequestId() string {
 
 func (d *DefaultAttainsRequest) WithBody(closer io.ReadCloser) AttainsRequest {
 	d.request.Body = closer
+	d.request.GetBody = nil
+	d.request.ContentLength = 0
+	d.sequentialBody = false
 	return d
 }
 
 func (d *DefaultAttainsRequest) WithBodyBytes(stream []byte) AttainsRequest {
 	d.request.Body = ioutil.NopCloser(bytes.NewReader(stream))
+	d.request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(stream)), nil
+	}
+	d.request.ContentLength = int64(len(stream))
+	d.sequentialBody = false
 	return d
 }
 
+// WithBodySeeker the reader must also be an io.ReaderAt to be read concurrently, otherwise every attempt
+// seeks it back to the offset and the request is never hedged
+func (d *DefaultAttainsRequest) WithBodySeeker(body io.ReadSeeker) AttainsRequest {
+	getBody, size, err := seekerGetBody(body)
+	if err != nil {
+		getBody = func() (io.ReadCloser, error) {
+			return nil, fmt.Errorf("seek request body failed: %v", err)
+		}
+	}
+	d.WithBodyStream(getBody, size, "")
+	_, readerAt := body.(io.ReaderAt)
+	d.sequentialBody = !readerAt
+	return d
+}
+
+func (d *DefaultAttainsRequest) WithBodyStream(getBody func() (io.ReadCloser, error), size int64, contentMd5 string) AttainsRequest {
+	// The body is opened by the client before the first attempt
+	d.request.Body = http.NoBody
+	d.sequentialBody = false
+	d.request.GetBody = getBody
+	d.request.ContentLength = size
+	// A stream is never compressed since its length is preset
+	d.request.Header.Set(metadata.RequestKeyContentLength, strconv.FormatInt(size, 10))
+	if contentMd5 != "" {
+		d.request.Header.Set(metadata.RequestKeyContentMd5, contentMd5)
+	}
+	return d
+}
+
+// seekerGetBody read the rest of body from its current offset for every attempt
+func seekerGetBody(body io.ReadSeeker) (func() (io.ReadCloser, error), int64, error) {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+	end, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	size := end - start
+	if at, ok := body.(io.ReaderAt); ok {
+		return func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(io.NewSectionReader(at, start, size)), nil
+		}, size, nil
+	}
+	return func() (io.ReadCloser, error) {
+		if _, err := body.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(io.LimitReader(body, size)), nil
+	}, size, nil
+}
+
 func (d *DefaultAttainsRequest) WithProxyUrl(u *url.URL) AttainsRequest {
 	d.proxyURL = u
 	return d
@@ -147,10 +260,112 @@ func (d *DefaultAttainsRequest) GetProxyUrl() *url.URL {
 	return d.proxyURL
 }
 
+func (d *DefaultAttainsRequest) WithEndpointOverride(endpoint string) AttainsRequest {
+	d.endpointOverride = endpoint
+	return d
+}
+
+func (d *DefaultAttainsRequest) GetEndpointOverride() string {
+	return d.endpointOverride
+}
+
+func (d *DefaultAttainsRequest) WithTimeout(timeout time.Duration) AttainsRequest {
+	d.timeout = timeout
+	return d
+}
+
+func (d *DefaultAttainsRequest) GetTimeout() time.Duration {
+	return d.timeout
+}
+
+func (d *DefaultAttainsRequest) WithRetryPolicy(policy retry.AttainsRetryPolicy) AttainsRequest {
+	d.retryPolicy = policy
+	return d
+}
+
+func (d *DefaultAttainsRequest) GetRetryPolicy() retry.AttainsRetryPolicy {
+	return d.retryPolicy
+}
+
+func (d *DefaultAttainsRequest) WithResponseMetadata(md *ResponseMetadata) AttainsRequest {
+	d.responseMetadata = md
+	return d
+}
+
+func (d *DefaultAttainsRequest) GetResponseMetadata() *ResponseMetadata {
+	return d.responseMetadata
+}
+
+func (d *DefaultAttainsRequest) WithService(service string) AttainsRequest {
+	d.service = service
+	return d
+}
+
+func (d *DefaultAttainsRequest) GetService() string {
+	return d.service
+}
+
+func (d *DefaultAttainsRequest) WithRoute(route string) AttainsRequest {
+	d.route = route
+	return d
+}
+
+func (d *DefaultAttainsRequest) GetRoute() string {
+	if d.route == "" {
+		return d.request.URL.Path
+	}
+	return d.route
+}
+
+func (d *DefaultAttainsRequest) WithDryRun(dryRun bool) AttainsRequest {
+	d.dryRun = dryRun
+	return d
+}
+
+func (d *DefaultAttainsRequest) IsDryRun() bool {
+	return d.dryRun
+}
+
 func (d *DefaultAttainsRequest) GetContext() context.Context {
 	return d.request.Context()
 }
 
+func (d *DefaultAttainsRequest) Clone(ctx context.Context) AttainsExtendedRequest {
+	request := d.request.Clone(ctx)
+	if d.request.GetBody != nil {
+		if body, err := d.request.GetBody(); err == nil {
+			request.Body = body
+		}
+	}
+	clone := *d
+	clone.request = request
+	return &clone
+}
+
 func (d *DefaultAttainsRequest) Build() *http.Request {
 	return d.request
 }
+
+// concurrentBody check whether the body of the request can be read by concurrent attempts
+func concurrentBody(request AttainsRequest) bool {
+	r, ok := request.(*DefaultAttainsRequest)
+	return !ok || !r.sequentialBody
+}
+
+// extend return the request with the call settings, a request implementing only AttainsRequest is copied
+// into a DefaultAttainsRequest sharing its http request
+func extend(request AttainsRequest) AttainsExtendedRequest {
+	if extended, ok := request.(AttainsExtendedRequest); ok {
+		return extended
+	}
+	req := request.Build()
+	if ctx := request.GetContext(); ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	return &DefaultAttainsRequest{
+		request:   req,
+		proxyURL:  request.GetProxyUrl(),
+		endpoint:  request.GetEndpoint(),
+		requestId: request.GetRequestId(),
+	}
+}
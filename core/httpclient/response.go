@@ -24,25 +24,21 @@ import (
 	"fmt"
 	"github.com/attains/attainscloud-sdk-go/core/errors"
 	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"time"
 )
 
-var (
-	dynamicResponseStructs = []reflect.StructField{
-		{
-			Name: "Code",
-			Type: reflect.TypeOf(int64(0)),
-			Tag:  "json:\"code\"",
-		},
-		{
-			Name: "Message",
-			Type: reflect.TypeOf(""),
-			Tag:  "json:\"message\"",
-		},
-	}
-)
+// envelope the common body of the responses, Data holds the pointer to the result so that the data is
+// decoded in place without an intermediate value
+type envelope struct {
+	Code    int64       `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
 
 type AttainsResponse interface {
 	SetResponse(*http.Response) AttainsResponse
@@ -51,10 +47,47 @@ type AttainsResponse interface {
 	ParseResponse(ctx context.Context) error
 }
 
+// optionsResponse a response reading its body with the ResponseOptions of the client, e.g. DefaultAttainsResponse
+type optionsResponse interface {
+	WithOptions(ResponseOptions) AttainsResponse
+}
+
+// ResponseOptions how the response body is read
+type ResponseOptions struct {
+	// MaxBytes the maximum size of the body, metadata.DefaultMaxResponseBytes if not positive
+	MaxBytes int64
+	// LogBody log the body at debug
+	LogBody bool
+	// LogBodyLimit the maximum bytes of the body logged, metadata.DefaultLogBodyLimit if not positive
+	LogBodyLimit int
+	// RedactBody redact the logged body, its second argument reports whether the body is truncated,
+	// the body is logged as is if nil
+	RedactBody func([]byte, bool) string
+}
+
+// ResponseMetadata the metadata of a call, e.g. the request ids for the support tickets
+type ResponseMetadata struct {
+	// RequestId the request id sent
+	RequestId string
+	// ServerRequestId the request id echoed by the server, empty if none
+	ServerRequestId string
+	// StatusCode and Header of the last response, zero if no response was received
+	StatusCode int
+	Header     http.Header
+	// Attempts the http requests sent, including the retries and the hedged ones
+	Attempts int
+	// Elapsed the duration of the whole call
+	Elapsed time.Duration
+
+	// attempts counts the attempts which may run concurrently
+	attempts int32
+}
+
 type DefaultAttainsResponse struct {
 	response *http.Response
 	logger   logger.Interface
 	target   interface{}
+	options  ResponseOptions
 }
 
 func NewDefaultAttainsResponse(result interface{}) AttainsResponse {
@@ -77,34 +110,160 @@ func (d *DefaultAttainsResponse) WithLogger(p logger.Interface) AttainsResponse
 	return d
 }
 
+func (d *DefaultAttainsResponse) WithOptions(options ResponseOptions) AttainsResponse {
+	d.options = options
+	return d
+}
+
+// branch create a response parsing into a new result of the same type, for a concurrent attempt
+func (d *DefaultAttainsResponse) branch() *DefaultAttainsResponse {
+	target := d.target
+	if vt := reflect.TypeOf(d.target); vt != nil && vt.Kind() == reflect.Ptr {
+		target = reflect.New(vt.Elem()).Interface()
+	}
+	return &DefaultAttainsResponse{
+		logger:  d.logger,
+		target:  target,
+		options: d.options,
+	}
+}
+
+// adopt take the http response and the result parsed by the branch
+func (d *DefaultAttainsResponse) adopt(b *DefaultAttainsResponse, parsed bool) {
+	d.response = b.response
+	if parsed && d.target != b.target {
+		reflect.ValueOf(d.target).Elem().Set(reflect.ValueOf(b.target).Elem())
+	}
+}
+
 func (d *DefaultAttainsResponse) ParseResponse(ctx context.Context) error {
-	buf, err := ioutil.ReadAll(d.response.Body)
-	d.logger.Debug(ctx, "Get raw response(%s),err(%v)", string(buf), err)
+	defer d.closeBody()
+	if vt := reflect.TypeOf(d.target); vt.Kind() != reflect.Ptr {
+		return errors.NewAttainsClientError(fmt.Sprintf("result (%s) must be an pointer", vt.String()))
+	}
+	maxBytes := d.options.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = metadata.DefaultMaxResponseBytes
+	}
+	if d.response.ContentLength > maxBytes {
+		return newResponseTooLargeError(maxBytes)
+	}
+	body := &limitedReader{r: d.response.Body, remaining: maxBytes}
+	var reader io.Reader = body
+	var logged *prefixBuffer
+	if d.options.LogBody {
+		logged = newPrefixBuffer(d.options.LogBodyLimit)
+		reader = io.TeeReader(body, logged)
+	}
+
+	e := envelope{Data: d.target}
+	err := json.NewDecoder(reader).Decode(&e)
+	if logged != nil {
+		d.logger.Debug(ctx, "Get raw response(%s),err(%v)", logged.redacted(d.options.RedactBody), err)
+	}
 	if err != nil {
+		if body.exceeded {
+			return newResponseTooLargeError(maxBytes)
+		}
+		// The gateway may answer an error status without the envelope, e.g. 429 or 502
+		if d.response.StatusCode >= http.StatusBadRequest {
+			return errors.NewAttainsServiceError(int64(d.response.StatusCode), http.StatusText(d.response.StatusCode))
+		}
 		return err
 	}
+	if e.Code != int64(http.StatusOK) {
+		return errors.NewAttainsServiceError(e.Code, e.Message)
+	}
+
+	return nil
+}
+
+// closeBody drain a bounded rest of the body so that the connection can be reused, then close it
+func (d *DefaultAttainsResponse) closeBody() {
+	if d.response == nil || d.response.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(d.response.Body, drainLimit))
 	d.response.Body.Close()
-	d.response.Body = ioutil.NopCloser(bytes.NewBuffer(buf))
+	d.response.Body = http.NoBody
+}
 
-	if vt := reflect.TypeOf(d.target); vt.Kind() != reflect.Ptr {
-		return errors.NewAttainsClientError(fmt.Sprintf("result (%s) must be an pointer", vt.String()))
+// drainLimit the maximum bytes drained from a parsed body before closing it
+const drainLimit = 4 * 1024
+
+func newResponseTooLargeError(maxBytes int64) error {
+	return errors.NewAttainsClientErrorWithKind(errors.ClientErrorKindResponseTooLarge,
+		fmt.Sprintf("response body exceeds the limit of %d bytes", maxBytes))
+}
+
+// limitedReader fail the read once more than remaining bytes are read
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		l.exceeded = true
+		return 0, errResponseTooLarge
 	}
-	sfs := append(dynamicResponseStructs, reflect.StructField{
-		Name: "Data",
-		Type: reflect.TypeOf(d.target),
-		Tag:  "json:\"data\"",
-	})
-	so := reflect.New(reflect.StructOf(sfs))
-	if err := json.Unmarshal(buf, so.Interface()); err != nil {
-		return err
+	// read one byte more than allowed to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
 	}
-	if code := so.Elem().FieldByName("Code").Int(); code != int64(http.StatusOK) {
-		message := so.Elem().FieldByName("Message").String()
-		return errors.NewAttainsServiceError(code, message)
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return n, errResponseTooLarge
 	}
-	if em := so.Elem().FieldByName("Data"); !em.IsZero() {
-		reflect.ValueOf(d.target).Elem().Set(em.Elem())
+	return n, err
+}
+
+var errResponseTooLarge = errors.NewAttainsClientErrorWithKind(errors.ClientErrorKindResponseTooLarge, "response body too large")
+
+// prefixBuffer keep the first limit bytes written and count the rest
+type prefixBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+func newPrefixBuffer(limit int) *prefixBuffer {
+	if limit <= 0 {
+		limit = metadata.DefaultLogBodyLimit
 	}
+	return &prefixBuffer{limit: limit}
+}
 
-	return nil
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if rest := b.limit - b.buf.Len(); rest > 0 {
+		if len(p) < rest {
+			rest = len(p)
+		}
+		b.buf.Write(p[:rest])
+	}
+	b.total += len(p)
+	return len(p), nil
+}
+
+func (b *prefixBuffer) String() string {
+	if b.total > b.buf.Len() {
+		return fmt.Sprintf("%s...(truncated, %d bytes read)", b.buf.String(), b.total)
+	}
+	return b.buf.String()
+}
+
+// redacted the String with the kept bytes redacted by redact
+func (b *prefixBuffer) redacted(redact func([]byte, bool) string) string {
+	if redact == nil {
+		return b.String()
+	}
+	truncated := b.total > b.buf.Len()
+	body := redact(b.buf.Bytes(), truncated)
+	if truncated {
+		return fmt.Sprintf("%s...(truncated, %d bytes read)", body, b.total)
+	}
+	return body
 }
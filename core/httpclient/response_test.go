@@ -0,0 +1,139 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// templateResult the shape of the QueryTemplate result of the sms service
+type templateResult struct {
+	TemplateId     string `json:"templateId"`
+	TemplateStatus string `json:"templateStatus"`
+	Uid            string `json:"uid"`
+	Name           string `json:"name"`
+	Content        string `json:"content"`
+	SmsType        string `json:"smsType"`
+	Description    string `json:"description"`
+	CountryType    string `json:"countryType"`
+	Review         string `json:"review"`
+	CreatedAt      string `json:"createdAt"`
+	AuditAt        string `json:"auditAt"`
+}
+
+// emptyResult the shape of the SendSms result of the sms service
+type emptyResult struct{}
+
+func templateBody(id string) []byte {
+	return []byte(`{"code":200,"message":"success","data":{"templateId":"` + id + `","templateStatus":"ENABLED",` +
+		`"uid":"u-1","name":"verify","content":"Your code is ${code}","smsType":"CommonNotice","description":"login",` +
+		`"countryType":"DOMESTIC","review":"","createdAt":"2023-06-01T08:00:00Z","auditAt":"2023-06-01T09:00:00Z"}}`)
+}
+
+var sendSmsBody = []byte(`{"code":200,"message":"success","data":{}}`)
+
+func parse(body []byte, result interface{}) error {
+	response := httpclient.NewDefaultAttainsResponse(result).WithLogger(logger.Discard)
+	response.SetResponse(&http.Response{
+		StatusCode:    http.StatusOK,
+		ContentLength: int64(len(body)),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	})
+	return response.ParseResponse(context.Background())
+}
+
+func BenchmarkParseResponse(b *testing.B) {
+	b.Run("QueryTemplate", func(b *testing.B) {
+		body := templateBody("t-1")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := parse(body, &templateResult{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("SendSms", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := parse(sendSmsBody, &emptyResult{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("SendSmsParallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := parse(sendSmsBody, &emptyResult{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+func TestParseResponseConcurrent(t *testing.T) {
+	const goroutines = 32
+	const iterations = 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if g%2 == 1 {
+					if err := parse(sendSmsBody, &emptyResult{}); err != nil {
+						t.Errorf("goroutine %d: %v", g, err)
+						return
+					}
+					continue
+				}
+				id := fmt.Sprintf("t-%d-%d", g, i)
+				result := &templateResult{}
+				if err := parse(templateBody(id), result); err != nil {
+					t.Errorf("goroutine %d: %v", g, err)
+					return
+				}
+				if result.TemplateId != id || result.Name != "verify" {
+					t.Errorf("goroutine %d parsed %+v, want templateId %s", g, result, id)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParseResponseServiceError(t *testing.T) {
+	result := &templateResult{}
+	err := parse([]byte(`{"code":404,"message":"template not found","data":null}`), result)
+	if err == nil || err.Error() == "" {
+		t.Fatalf("err = %v, want the service error", err)
+	}
+	if *result != (templateResult{}) {
+		t.Errorf("result = %+v, want it untouched", result)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/auth"
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retryAll retry every error a few times, the client decides which calls may be retried at all
+type retryAll struct{}
+
+func (retryAll) ShouldRetry(_ errors.AttainsError, attempts int) bool {
+	return attempts < 3
+}
+
+func (retryAll) GetDelayBeforeNextRetryInMillis(errors.AttainsError, int) time.Duration {
+	return 50 * time.Millisecond
+}
+
+// deadEndpoint an address nothing listens on
+func deadEndpoint(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	return "http://" + addr
+}
+
+func sendPost(client httpclient.AttainsHttpClient, idempotencyKey string) error {
+	request := httpclient.NewDefaultAttainsRequest(context.Background(), nil).
+		WithMethod(http.MethodPost).WithPath("/sms/send").WithBodyBytes([]byte(`{"templateId":"t-1"}`))
+	if idempotencyKey != "" {
+		httpclient.ApplyCallOptions(request, httpclient.CallIdempotencyKey(idempotencyKey))
+	}
+	return client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&struct{}{}))
+}
+
+func TestRetryFailoverBeforeSend(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{}}`)
+	}))
+	defer server.Close()
+
+	conf := newTestConfig("")
+	conf.Endpoints = []string{deadEndpoint(t), server.URL}
+	conf.Retry = retry.NewAttainsBackoffRetryPolicy(2, 10, 1)
+	client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, conf)
+
+	// The dead endpoint refused the connection, so the call without a key was never applied
+	if err := sendPost(client, ""); err != nil {
+		t.Fatalf("POST without key did not fail over: %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("healthy endpoint hits = %d, want 1", n)
+	}
+}
+
+func TestRetryAfterSend(t *testing.T) {
+	cases := []struct {
+		name           string
+		method         string
+		idempotencyKey string
+		wantHits       int32
+	}{
+		{"GET", http.MethodGet, "", 3},
+		{"POST without key", http.MethodPost, "", 1},
+		{"POST with key", http.MethodPost, "send-1", 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var hits int32
+			// Every attempt reaches the server, which drops the connection without an answer
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					_ = conn.Close()
+				}
+			}))
+			defer server.Close()
+
+			conf := newTestConfig(server.URL)
+			conf.Retry = retry.NewAttainsBackoffRetryPolicy(2, 10, 1)
+			client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, conf)
+
+			request := httpclient.NewDefaultAttainsRequest(context.Background(), nil).
+				WithMethod(c.method).WithPath("/sms/send").WithBodyBytes([]byte(`{"templateId":"t-1"}`))
+			if c.idempotencyKey != "" {
+				httpclient.ApplyCallOptions(request, httpclient.CallIdempotencyKey(c.idempotencyKey))
+			}
+			if err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(&struct{}{})); err == nil {
+				t.Fatal("err = nil, want the network error")
+			}
+			if n := atomic.LoadInt32(&hits); n != c.wantHits {
+				t.Errorf("server hits = %d, want %d", n, c.wantHits)
+			}
+		})
+	}
+}
+
+func TestRetryCircuitOpen(t *testing.T) {
+	var hits, failing int32 = 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"code":500,"message":"internal error"}`)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{}}`)
+	}))
+	defer server.Close()
+
+	conf := newTestConfig(server.URL)
+	conf.Retry = retryAll{}
+	client := httpclient.NewAttainsHttpClient(&auth.AttainsV1Signer{}, conf,
+		httpclient.WithCircuitBreaker(httpclient.CircuitBreakerSettings{
+			FailureRatio: 1,
+			MinRequests:  1,
+			OpenDuration: 30 * time.Millisecond,
+		}))
+
+	// The failure reached the server, the call without a key is not retried and opens the circuit
+	if err := sendPost(client, ""); err == nil {
+		t.Fatal("err = nil, want the service error")
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("server hits = %d, want 1", n)
+	}
+
+	// The open circuit sends nothing, so the call is retried once the circuit lets a probe through
+	atomic.StoreInt32(&failing, 0)
+	if err := sendPost(client, ""); err != nil {
+		t.Fatalf("POST without key was not retried after the circuit open error: %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("server hits = %d, want 2", n)
+	}
+}
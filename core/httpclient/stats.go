@@ -0,0 +1,200 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	FailureKindService  = "Service"
+	FailureKindCanceled = "Canceled"
+	FailureKindNetwork  = "Network"
+	FailureKindOther    = "Other"
+	FailureKindDryRun   = "DryRun"
+)
+
+// LatencyBuckets the upper bounds of the latency histograms
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats a snapshot of the counters of a client
+type Stats struct {
+	// InFlight the calls running now
+	InFlight int64
+	// Requests the calls started, every call may make several attempts
+	Requests uint64
+	// Attempts the http requests sent
+	Attempts uint64
+	// Retries the attempts made after a failed one
+	Retries uint64
+	// Failures the failed calls by kind, the kind of an AttainsClientError or one of the FailureKinds
+	Failures map[string]uint64
+	// ReusedConns and NewConns the attempts sent on an idle connection and on a new one
+	ReusedConns uint64
+	NewConns    uint64
+	// Endpoints the latency of the attempts by host
+	Endpoints map[string]Histogram
+}
+
+// Histogram the distribution of the latencies
+type Histogram struct {
+	// Buckets the upper bounds of the buckets, i.e. LatencyBuckets
+	Buckets []time.Duration
+	// Counts the observations of every bucket, the last one counts those greater than all the bounds
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// clientStats the counters of a client, the atomic ones come first for the 64-bit alignment
+type clientStats struct {
+	inFlight    int64
+	requests    uint64
+	attempts    uint64
+	retries     uint64
+	reusedConns uint64
+	newConns    uint64
+
+	mu        sync.Mutex
+	failures  map[string]uint64
+	endpoints map[string]*Histogram
+	trace     *httptrace.ClientTrace
+}
+
+func newClientStats() *clientStats {
+	s := &clientStats{
+		failures:  map[string]uint64{},
+		endpoints: map[string]*Histogram{},
+	}
+	s.trace = &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddUint64(&s.reusedConns, 1)
+			} else {
+				atomic.AddUint64(&s.newConns, 1)
+			}
+		},
+	}
+	return s
+}
+
+// observe count an attempt and its latency
+func (s *clientStats) observe(host string, latency time.Duration) {
+	atomic.AddUint64(&s.attempts, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.endpoints[host]
+	if !ok {
+		h = &Histogram{
+			Buckets: LatencyBuckets,
+			Counts:  make([]uint64, len(LatencyBuckets)+1),
+		}
+		s.endpoints[host] = h
+	}
+	i := 0
+	for i < len(h.Buckets) && latency > h.Buckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += latency
+}
+
+func (s *clientStats) fail(err error) {
+	kind := failureKind(err)
+	s.mu.Lock()
+	s.failures[kind]++
+	s.mu.Unlock()
+}
+
+func failureKind(err error) string {
+	switch realErr := err.(type) {
+	case *errors.AttainsClientError:
+		return string(realErr.Kind())
+	case *errors.AttainsServiceError:
+		return FailureKindService
+	case *errors.AttainsCanceledError:
+		return FailureKindCanceled
+	case net.Error:
+		return FailureKindNetwork
+	case *DryRunError:
+		return FailureKindDryRun
+	}
+	return FailureKindOther
+}
+
+// statsMiddleware count the calls and their failures
+func (d *DefaultAttainsHttpClient) statsMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		atomic.AddUint64(&d.stats.requests, 1)
+		atomic.AddInt64(&d.stats.inFlight, 1)
+		defer atomic.AddInt64(&d.stats.inFlight, -1)
+		err := next(request, response)
+		if _, dryRun := err.(*DryRunError); err != nil && !dryRun {
+			d.stats.fail(err)
+		}
+		return err
+	}
+}
+
+// Stats return a snapshot of the counters of the client, e.g. to tune the transport or to alert on degradation
+func (d *DefaultAttainsHttpClient) Stats() Stats {
+	s := d.stats
+	stats := Stats{
+		InFlight:    atomic.LoadInt64(&s.inFlight),
+		Requests:    atomic.LoadUint64(&s.requests),
+		Attempts:    atomic.LoadUint64(&s.attempts),
+		Retries:     atomic.LoadUint64(&s.retries),
+		ReusedConns: atomic.LoadUint64(&s.reusedConns),
+		NewConns:    atomic.LoadUint64(&s.newConns),
+		Failures:    map[string]uint64{},
+		Endpoints:   map[string]Histogram{},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for kind, n := range s.failures {
+		stats.Failures[kind] = n
+	}
+	for host, h := range s.endpoints {
+		counts := make([]uint64, len(h.Counts))
+		copy(counts, h.Counts)
+		stats.Endpoints[host] = Histogram{
+			Buckets: h.Buckets,
+			Counts:  counts,
+			Count:   h.Count,
+			Sum:     h.Sum,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"io/ioutil"
+)
+
+// newTLSConfig build the tls config of the transport from the options
+func newTLSConfig(opt *config.TLSOptions) (*tls.Config, error) {
+	if opt == nil {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		RootCAs:      opt.RootCAs,
+		Certificates: append([]tls.Certificate(nil), opt.Certificates...),
+		MinVersion:   opt.MinVersion,
+	}
+
+	if tlsConfig.RootCAs == nil && opt.RootCAFile != "" {
+		pem, err := ioutil.ReadFile(opt.RootCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read root ca file failed: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in root ca file %s", opt.RootCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opt.CertFile != "" || opt.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opt.CertFile, opt.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed: %v", err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if len(opt.PinnedSPKIHashes) > 0 {
+		pins := make(map[string]struct{}, len(opt.PinnedSPKIHashes))
+		for _, pin := range opt.PinnedSPKIHashes {
+			pins[pin] = struct{}{}
+		}
+		tlsConfig.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			for _, chain := range verifiedChains {
+				for _, cert := range chain {
+					sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					if _, ok := pins[base64.StdEncoding.EncodeToString(sum[:])]; ok {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("no pinned public key found in the certificate chain")
+		}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package httpclient
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"net/http"
+	"sync/atomic"
+)
+
+// The tags of the spans
+const (
+	TagService     = "service"
+	TagMethod      = "http.method"
+	TagPath        = "path"
+	TagRequestId   = "request_id"
+	TagStatusCode  = "http.status_code"
+	TagServiceCode = "service.code"
+	TagRetryCount  = "retry.count"
+	TagAttempt     = "attempt"
+	TagHost        = "host"
+)
+
+// The names of the spans
+const (
+	SpanNameCall    = "attains.call"
+	SpanNameAttempt = "attains.attempt"
+)
+
+// Tracer start the spans of the calls, e.g. an adapter of OpenTelemetry
+type Tracer interface {
+	// StartSpan start a span as a child of the span in ctx, return the context holding the new span
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span a span started by a Tracer
+type Span interface {
+	SetTag(key string, value interface{})
+	// TraceContext return the W3C traceparent and tracestate of the span to send, empty if none
+	TraceContext() (traceparent, tracestate string)
+	// Finish end the span with the error of the call or the attempt, nil on success
+	Finish(err error)
+}
+
+// WithTracer start a span for every call and a child span for every attempt
+func WithTracer(tracer Tracer) ClientOption {
+	return func(d *DefaultAttainsHttpClient) {
+		d.tracer = tracer
+	}
+}
+
+type traceContextKey struct{}
+
+type traceContext struct {
+	traceparent string
+	tracestate  string
+}
+
+// ContextWithTraceContext return a context whose calls send the W3C traceparent and tracestate, e.g. the ones
+// received by a server without tracer, the trace context of the spans of a Tracer takes precedence
+func ContextWithTraceContext(ctx context.Context, traceparent, tracestate string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext{traceparent: traceparent, tracestate: tracestate})
+}
+
+type traceAttemptsKey struct{}
+
+// traceMiddleware start the span of the call
+func (d *DefaultAttainsHttpClient) traceMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		if d.tracer == nil {
+			return next(request, response)
+		}
+		ctx, span := d.tracer.StartSpan(request.GetContext(), SpanNameCall)
+		attempts, retries := new(int32), new(int32)
+		ctx = context.WithValue(ctx, traceAttemptsKey{}, attempts)
+		request = withContext(request, context.WithValue(ctx, callRetriesKey{}, retries))
+		req := request.Build()
+		span.SetTag(TagService, extend(request).GetService())
+		span.SetTag(TagMethod, req.Method)
+		span.SetTag(TagPath, extend(request).GetRoute())
+		span.SetTag(TagRequestId, req.Header.Get(metadata.RequestKeyAttainsRequestId))
+
+		err := next(request, response)
+		// The attempts include the hedged ones, the retries are counted by the retry loop
+		span.SetTag(TagRetryCount, int(atomic.LoadInt32(retries)))
+		tagResult(span, response, err)
+		span.Finish(err)
+		return err
+	}
+}
+
+// attemptTraceMiddleware start the span of the attempt and send its trace context
+func (d *DefaultAttainsHttpClient) attemptTraceMiddleware(next Handler) Handler {
+	return func(request AttainsRequest, response AttainsResponse) error {
+		ctx := request.GetContext()
+		tc, _ := ctx.Value(traceContextKey{}).(traceContext)
+		var span Span
+		if d.tracer != nil {
+			ctx, span = d.tracer.StartSpan(ctx, SpanNameAttempt)
+			if attempts, ok := ctx.Value(traceAttemptsKey{}).(*int32); ok {
+				span.SetTag(TagAttempt, int(atomic.AddInt32(attempts, 1)))
+			}
+			if traceparent, tracestate := span.TraceContext(); traceparent != "" {
+				tc = traceContext{traceparent: traceparent, tracestate: tracestate}
+			}
+			request = withContext(request, ctx)
+		}
+		if tc.traceparent != "" {
+			req := request.Build()
+			req.Header.Set(metadata.RequestKeyTraceParent, tc.traceparent)
+			if tc.tracestate != "" {
+				req.Header.Set(metadata.RequestKeyTraceState, tc.tracestate)
+			} else {
+				req.Header.Del(metadata.RequestKeyTraceState)
+			}
+		}
+		if span == nil {
+			return next(request, response)
+		}
+
+		err := next(request, response)
+		span.SetTag(TagHost, request.Build().URL.Host)
+		tagResult(span, response, err)
+		span.Finish(err)
+		return err
+	}
+}
+
+// tagResult tag the http status and the service code of the response
+func tagResult(span Span, response AttainsResponse, err error) {
+	if httpResponse := response.GetResponse(); httpResponse != nil {
+		span.SetTag(TagStatusCode, httpResponse.StatusCode)
+	}
+	switch realErr := err.(type) {
+	case nil:
+		span.SetTag(TagServiceCode, int64(http.StatusOK))
+	case *errors.AttainsServiceError:
+		span.SetTag(TagServiceCode, realErr.Code())
+	}
+}
@@ -30,6 +30,21 @@ const (
 	DefaultKeepAliveTimeout          = 30 * time.Second
 	DefaultContentType               = "application/json;charset=utf-8"
 	DefaultConnectionTimeoutInMillis = 1200 * 1000
+	DefaultEndpointCooldown          = 30 * time.Second
+	DefaultHedgingDelay              = 100 * time.Millisecond
+	DefaultHedgingMaxAttempts        = 2
+	DefaultCompressionThreshold      = 8 * 1024
+	DefaultMaxResponseBytes          = 16 * 1024 * 1024
+	DefaultLogBodyLimit              = 1024
+	DefaultAsyncWorkers              = 16
+)
+
+const (
+	DefaultCircuitFailureRatio        = 0.5
+	DefaultCircuitMinRequests         = 10
+	DefaultCircuitOpenDuration        = 30 * time.Second
+	DefaultCircuitInterval            = 60 * time.Second
+	DefaultCircuitHalfOpenMaxRequests = 1
 )
 
 const (
@@ -42,7 +57,16 @@ const (
 	RequestKeyHost          = "HOST"
 	RequestKeyUserAgent     = "User-Agent"
 
+	RequestKeyContentEncoding = "Content-Encoding"
+	ContentEncodingGzip       = "gzip"
+
 	RequestKeyAttainsPrefix    = "x-attains-"
 	RequestKeyAttainsRequestId = "x-attains-request-id"
 	RequestKeyAttainsDate      = "x-attains-date"
+
+	RequestKeyAttainsIdempotencyKey = "x-attains-idempotency-key"
+
+	// The W3C trace context, never signed since the proxies may change it
+	RequestKeyTraceParent = "traceparent"
+	RequestKeyTraceState  = "tracestate"
 )
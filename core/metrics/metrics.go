@@ -0,0 +1,302 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+// Package metrics metrics.go - counters and histograms of the calls with a Prometheus text exposition
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The metrics of the calls collected by httpclient.WithMetrics
+const (
+	RequestsTotal    = "attains_sdk_requests_total"
+	RetriesTotal     = "attains_sdk_retries_total"
+	AttemptsPerCall  = "attains_sdk_attempts_per_call"
+	RequestDuration  = "attains_sdk_request_duration_seconds"
+	RequestsInFlight = "attains_sdk_requests_in_flight"
+)
+
+// The labels of the metrics of the calls
+const (
+	LabelService = "service"
+	LabelPath    = "path"
+	LabelMethod  = "method"
+	LabelStatus  = "status"
+	LabelCode    = "code"
+)
+
+var (
+	// DefaultBuckets the upper bounds of the histograms in seconds
+	DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// AttemptsBuckets the upper bounds of the histogram of the attempts per call
+	AttemptsBuckets = []float64{1, 2, 3, 4, 5, 10}
+)
+
+// Labels the label values of a series
+type Labels map[string]string
+
+// Collector receives the metrics, e.g. a Registry or an adapter of another metrics library
+type Collector interface {
+	// AddCounter add delta to the counter
+	AddCounter(name string, labels Labels, delta float64)
+	// AddGauge add delta to the gauge, negative to decrease it
+	AddGauge(name string, labels Labels, delta float64)
+	// ObserveHistogram add an observation to the histogram
+	ObserveHistogram(name string, labels Labels, value float64)
+}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+type series struct {
+	labels string
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type family struct {
+	kind    string
+	buckets []float64
+	series  map[string]*series
+}
+
+// Registry a Collector keeping the metrics in memory, it serves them in the Prometheus text format
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+	help     map[string]string
+	buckets  map[string][]float64
+}
+
+// NewRegistry create a registry knowing the help and the buckets of the metrics of the calls
+func NewRegistry() *Registry {
+	return &Registry{
+		families: map[string]*family{},
+		help: map[string]string{
+			RequestsTotal:    "The calls by service, path, method, http status and code.",
+			RetriesTotal:     "The retried attempts by service, path and method.",
+			AttemptsPerCall:  "The attempts of every call by service, path and method.",
+			RequestDuration:  "The duration of the calls in seconds by service, path and method.",
+			RequestsInFlight: "The calls running by service.",
+		},
+		buckets: map[string][]float64{
+			AttemptsPerCall: AttemptsBuckets,
+		},
+	}
+}
+
+// Describe set the help of the metric
+func (r *Registry) Describe(name, help string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.help[name] = help
+}
+
+// SetBuckets set the upper bounds of the histogram before its first observation, DefaultBuckets if not set
+func (r *Registry) SetBuckets(name string, buckets []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	r.buckets[name] = sorted
+}
+
+func (r *Registry) AddCounter(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.series(name, kindCounter, labels); s != nil {
+		s.value += delta
+	}
+}
+
+func (r *Registry) AddGauge(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.series(name, kindGauge, labels); s != nil {
+		s.value += delta
+	}
+}
+
+func (r *Registry) ObserveHistogram(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.series(name, kindHistogram, labels)
+	if s == nil {
+		return
+	}
+	buckets := r.families[name].buckets
+	if s.counts == nil {
+		s.counts = make([]uint64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// series get or create the series, nil if the name is used by a metric of another kind
+func (r *Registry) series(name, kind string, labels Labels) *series {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{
+			kind:   kind,
+			series: map[string]*series{},
+		}
+		if kind == kindHistogram {
+			f.buckets = r.buckets[name]
+			if f.buckets == nil {
+				f.buckets = DefaultBuckets
+			}
+		}
+		r.families[name] = f
+	}
+	if f.kind != kind {
+		return nil
+	}
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Write render the metrics in the Prometheus text format, the registry is locked only while
+// rendering so a slow scraper does not block the calls recording metrics
+func (r *Registry) Write(w io.Writer) error {
+	var buf bytes.Buffer
+	r.render(&buf)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (r *Registry) render(bw *bytes.Buffer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := r.families[name]
+		if help, ok := r.help[name]; ok {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != kindHistogram {
+				fmt.Fprintf(bw, "%s%s %s\n", name, braces(s.labels), formatFloat(s.value))
+				continue
+			}
+			for i, bound := range f.buckets {
+				var count uint64
+				if s.counts != nil {
+					count = s.counts[i]
+				}
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", name, braces(joinLabels(s.labels, `le="`+formatFloat(bound)+`"`)), count)
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", name, braces(joinLabels(s.labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", name, braces(s.labels), formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", name, braces(s.labels), s.count)
+		}
+	}
+}
+
+// ServeHTTP serve the metrics for the Prometheus scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// formatLabels render the labels sorted by name, e.g. method="GET",path="/balance"
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(labels[name])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
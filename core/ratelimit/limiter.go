@@ -0,0 +1,123 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+// Package ratelimit limiter.go - token bucket limiter to smooth the requests on the client side
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrExceedsDeadline is returned when no token is available before the deadline of the context
+var ErrExceedsDeadline = errors.New("rate limit wait would exceed context deadline")
+
+// Limiter a token bucket which is refilled at qps tokens per second up to burst tokens
+type Limiter struct {
+	qps   float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter create a limiter with a full bucket, burst less than 1 is treated as 1
+func NewLimiter(qps float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		qps:    qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait block until a token is available or the context is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil || l.qps <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.advance(now)
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(math.Ceil(-l.tokens / l.qps * float64(time.Second)))
+	}
+	if deadline, ok := ctx.Deadline(); ok && delay > 0 && now.Add(delay).After(deadline) {
+		l.tokens++
+		l.mu.Unlock()
+		return ErrExceedsDeadline
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the reserved token
+		l.mu.Lock()
+		l.advance(time.Now())
+		l.tokens = math.Min(l.tokens+1, l.burst)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// advance refill the bucket by the time elapsed, must hold the lock
+func (l *Limiter) advance(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.tokens+elapsed.Seconds()*l.qps, l.burst)
+		l.last = now
+	}
+}
+
+// PathLimiters limiters keyed by request path, a key also applies to the sub paths of it
+type PathLimiters map[string]*Limiter
+
+// Get return the limiter of the longest key matching the path, nil if none matches
+func (p PathLimiters) Get(path string) *Limiter {
+	if limiter, ok := p[path]; ok {
+		return limiter
+	}
+	var matched string
+	var limiter *Limiter
+	for key, l := range p {
+		prefix := strings.TrimSuffix(key, "/")
+		if len(prefix) > len(matched) && strings.HasPrefix(path, prefix+"/") {
+			matched, limiter = prefix, l
+		}
+	}
+	return limiter
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+// Package recorder recorder.go - record the http interactions into cassette files and replay them offline
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/metadata"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode how the recorder deals with the requests
+type Mode int
+
+const (
+	// ModeRecord send every request and record the interaction
+	ModeRecord Mode = iota
+	// ModeReplay replay the recorded interactions only, a request without record fails
+	ModeReplay
+	// ModeReplayOrRecord replay the recorded interaction if any, otherwise send and record it
+	ModeReplayOrRecord
+)
+
+// NormalizedValue replaces the values of the normalized headers in the cassette
+const NormalizedValue = "[normalized]"
+
+// DefaultNormalizedHeaders the headers changing on every request, they are never compared when matching
+var DefaultNormalizedHeaders = []string{
+	metadata.RequestKeyAuthorization,
+	metadata.RequestKeyAttainsDate,
+	metadata.RequestKeyAttainsRequestId,
+	metadata.RequestKeyAttainsIdempotencyKey,
+	metadata.RequestKeyTraceParent,
+	metadata.RequestKeyTraceState,
+}
+
+// RecordedRequest the request part of an interaction, the bodies are kept as bytes (base64 in the
+// cassette) so that binary and compressed bodies are replayed as they were sent
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// RecordedResponse the response part of an interaction
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// Interaction a request and the response of it
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette the interactions saved in one file
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Matcher decide whether the recorded request matches the request with the body
+type Matcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+
+// MatchMethodAndURL match the method, the path and the query, the host is ignored
+func MatchMethodAndURL(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	if req.Method != recorded.Method {
+		return false
+	}
+	u, err := req.URL.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return u.Path == req.URL.Path && u.Query().Encode() == req.URL.Query().Encode()
+}
+
+// MatchMethodURLAndBody match the body besides the method and the url, it is the default matcher
+func MatchMethodURLAndBody(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	return MatchMethodAndURL(req, body, recorded) && bytes.Equal(body, recorded.Body)
+}
+
+// Option configures the Recorder
+type Option func(*Recorder)
+
+// WithMatcher set how the requests are matched when replaying
+func WithMatcher(matcher Matcher) Option {
+	return func(r *Recorder) {
+		r.matcher = matcher
+	}
+}
+
+// WithNormalizedHeaders normalize the headers besides DefaultNormalizedHeaders
+func WithNormalizedHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		r.normalizedHeaders = append(r.normalizedHeaders, headers...)
+	}
+}
+
+// Recorder an http.RoundTripper recording and replaying the interactions of a cassette file
+type Recorder struct {
+	path              string
+	mode              Mode
+	next              http.RoundTripper
+	matcher           Matcher
+	normalizedHeaders []string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	replayed []bool
+}
+
+// New create a recorder of the cassette file, next sends the requests not replayed, http.DefaultTransport if nil
+func New(path string, mode Mode, next http.RoundTripper, opts ...Option) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{
+		path:              path,
+		mode:              mode,
+		next:              next,
+		matcher:           MatchMethodURLAndBody,
+		normalizedHeaders: append([]string(nil), DefaultNormalizedHeaders...),
+		cassette:          &Cassette{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if mode != ModeRecord {
+		data, err := ioutil.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, r.cassette); err != nil {
+				return nil, fmt.Errorf("load cassette %s failed: %v", path, err)
+			}
+		case os.IsNotExist(err) && mode == ModeReplayOrRecord:
+		default:
+			return nil, fmt.Errorf("load cassette %s failed: %v", path, err)
+		}
+	}
+	r.replayed = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// RoundTrip replay or send the request according to the mode
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, out, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode != ModeRecord {
+		if interaction := r.find(req, body); interaction != nil {
+			closeBody(out)
+			return replay(req, interaction), nil
+		}
+		if r.mode == ModeReplay {
+			closeBody(out)
+			return nil, fmt.Errorf("no interaction recorded in %s for %s %s", r.path, req.Method, req.URL)
+		}
+	}
+
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.normalize(req.Header),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.normalize(resp.Header),
+			Body:       respBody,
+		},
+	})
+	r.replayed = append(r.replayed, true)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Save write the cassette into the file, the directories are created if needed
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
+
+// CloseIdleConnections close the idle connections of the next round tripper
+func (r *Recorder) CloseIdleConnections() {
+	if c, ok := r.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// find return the first matched interaction not replayed yet, or the last matched one
+func (r *Recorder) find(req *http.Request, body []byte) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var last *Interaction
+	for i, interaction := range r.cassette.Interactions {
+		if !r.matcher(req, body, &interaction.Request) {
+			continue
+		}
+		if !r.replayed[i] {
+			r.replayed[i] = true
+			return interaction
+		}
+		last = interaction
+	}
+	return last
+}
+
+func (r *Recorder) normalize(header http.Header) http.Header {
+	normalized := make(http.Header, len(header))
+	for k, vv := range header {
+		normalized[k] = append([]string(nil), vv...)
+	}
+	for _, key := range r.normalizedHeaders {
+		for k := range normalized {
+			if strings.EqualFold(k, key) {
+				normalized[k] = []string{NormalizedValue}
+			}
+		}
+	}
+	return normalized
+}
+
+// readBody read the body of the request without modifying it, the request to send is returned with
+// a fresh body: the request itself if the body can be read again by GetBody, otherwise a clone of it
+func readBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := ioutil.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		return body, req, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, out, nil
+}
+
+// closeBody close the body of a request not sent, a RoundTripper must always close it
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+func replay(req *http.Request, interaction *Interaction) *http.Response {
+	header := make(http.Header, len(interaction.Response.Header))
+	for k, vv := range interaction.Response.Header {
+		header[k] = append([]string(nil), vv...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package recorder_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/attains/attainscloud-sdk-go/core/auth"
+	"github.com/attains/attainscloud-sdk-go/core/config"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+	"github.com/attains/attainscloud-sdk-go/core/logger"
+	"github.com/attains/attainscloud-sdk-go/core/recorder"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type sendResult struct {
+	MessageId string `json:"messageId"`
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func send(t *testing.T, rec *recorder.Recorder, endpoint string, body []byte) (*sendResult, error) {
+	conf := &config.AttainsCustomConfig{
+		Endpoint:    endpoint,
+		Credentials: &auth.AttainsCredentials{AccessKeyId: "ak", SecretAccessKey: "sk"},
+		SignOption:  &auth.SignOptions{ExpireSeconds: 1800},
+		Retry:       retry.NewAttainsNoRetryPolicy(),
+		Logger:      logger.Discard,
+		Compression: &config.CompressionOptions{Threshold: 1},
+	}
+	client := httpclient.NewCustomHttpClient(&auth.AttainsV1Signer{}, conf, &http.Client{}, rec)
+	request := httpclient.NewDefaultAttainsRequest(context.Background(), nil).
+		WithMethod(http.MethodPost).WithPath("/sms/send").WithBodyBytes(body)
+	result := &sendResult{}
+	err := client.SendRequest(request, httpclient.NewDefaultAttainsResponse(result))
+	return result, err
+}
+
+func TestRecordAndReplayCompressedBody(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		fmt.Fprint(w, `{"code":200,"message":"ok","data":{"messageId":"m-1"}}`)
+	}))
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassettes", "send.json")
+	body := []byte(`{"mobile":"13800000000","templateId":"t-1","contentVars":{"code":"1234"}}`)
+
+	rec, err := recorder.New(path, recorder.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := send(t, rec, server.URL, body); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	rec, err = recorder.New(path, recorder.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := send(t, rec, server.URL, body)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if result.MessageId != "m-1" {
+		t.Errorf("replayed messageId = %q, want m-1", result.MessageId)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("server hits = %d, want 1", n)
+	}
+
+	if _, err := send(t, rec, server.URL, []byte(`{"mobile":"13900000000"}`)); err == nil ||
+		!strings.Contains(err.Error(), "no interaction recorded") {
+		t.Errorf("replay of another body: err = %v, want no interaction recorded", err)
+	}
+}
+
+func TestRoundTripDoesNotModifyRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	rec, err := recorder.New(filepath.Join(dir, "send.json"), recorder.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte{0x1f, 0x8b, 0xff, 0x00, 0xfe}
+	for _, withGetBody := range []bool{true, false} {
+		req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !withGetBody {
+			req.GetBody = nil
+			req.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		}
+		body := req.Body
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if !bytes.Equal(data, payload) {
+			t.Errorf("GetBody %v: echoed body = %x, want %x", withGetBody, data, payload)
+		}
+		if req.Body != body {
+			t.Errorf("GetBody %v: RoundTrip replaced the request body", withGetBody)
+		}
+	}
+}
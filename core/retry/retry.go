@@ -21,14 +21,73 @@ import (
 	"github.com/attains/attainscloud-sdk-go/core/errors"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	RetryAfterHeader = "Retry-After"
+)
+
 type AttainsRetryPolicy interface {
 	ShouldRetry(errors.AttainsError, int) bool
 	GetDelayBeforeNextRetryInMillis(errors.AttainsError, int) time.Duration
 }
 
+// RetryState the state of a call when one of its attempts failed
+type RetryState struct {
+	Attempts   int            // the retries made so far
+	Response   *http.Response // the http response of the failed attempt, nil on transport error
+	TotalDelay time.Duration  // the sum of the delays already waited in this call
+}
+
+// AttainsResponseRetryPolicy is implemented by the policies which inspect the http response,
+// it takes precedence over the AttainsRetryPolicy methods when implemented
+type AttainsResponseRetryPolicy interface {
+	AttainsRetryPolicy
+	ShouldRetryWithResponse(errors.AttainsError, *RetryState) bool
+	GetDelayBeforeNextRetryWithResponse(errors.AttainsError, *RetryState) time.Duration
+}
+
+// ShouldRetry ask the policy whether to retry, passing the response when the policy supports it
+func ShouldRetry(policy AttainsRetryPolicy, err errors.AttainsError, state *RetryState) bool {
+	if p, ok := policy.(AttainsResponseRetryPolicy); ok {
+		return p.ShouldRetryWithResponse(err, state)
+	}
+	return policy.ShouldRetry(err, state.Attempts)
+}
+
+// GetDelayBeforeNextRetry ask the policy for the delay, passing the response when the policy supports it
+func GetDelayBeforeNextRetry(policy AttainsRetryPolicy, err errors.AttainsError, state *RetryState) time.Duration {
+	if p, ok := policy.(AttainsResponseRetryPolicy); ok {
+		return p.GetDelayBeforeNextRetryWithResponse(err, state)
+	}
+	return policy.GetDelayBeforeNextRetryInMillis(err, state.Attempts)
+}
+
+// ParseRetryAfter parse the Retry-After header, both delay-seconds and http-date are supported
+func ParseRetryAfter(header http.Header) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get(RetryAfterHeader))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
 type AttainsNoRetryPolicy struct {
 }
 
@@ -99,3 +158,88 @@ func (a AttainsBackoffRetryPolicy) GetDelayBeforeNextRetryInMillis(_ errors.Atta
 func NewAttainsBackoffRetryPolicy(maxRetry int, maxDelay, base int64) AttainsRetryPolicy {
 	return &AttainsBackoffRetryPolicy{maxRetry, maxDelay, base}
 }
+
+// AttainsThrottlingRetryPolicy backoff retry policy which also retries throttled calls,
+// waiting as long as the server suggests by Retry-After and never longer than the total delay in one call,
+// a call is not retried if the server asks to wait longer than the max delay
+type AttainsThrottlingRetryPolicy struct {
+	AttainsBackoffRetryPolicy
+	maxTotalDelayInMillis int64
+	throttlingCodes       map[int64]struct{}
+}
+
+func (a AttainsThrottlingRetryPolicy) isThrottled(err errors.AttainsError, resp *http.Response) bool {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if realErr, ok := err.(*errors.AttainsServiceError); ok {
+		_, throttled := a.throttlingCodes[realErr.Code()]
+		return throttled
+	}
+	return false
+}
+
+func (a AttainsThrottlingRetryPolicy) ShouldRetry(err errors.AttainsError, attempts int) bool {
+	return a.ShouldRetryWithResponse(err, &RetryState{Attempts: attempts})
+}
+
+func (a AttainsThrottlingRetryPolicy) GetDelayBeforeNextRetryInMillis(err errors.AttainsError, attempts int) time.Duration {
+	return a.GetDelayBeforeNextRetryWithResponse(err, &RetryState{Attempts: attempts})
+}
+
+func (a AttainsThrottlingRetryPolicy) ShouldRetryWithResponse(err errors.AttainsError, state *RetryState) bool {
+	if state.Attempts >= a.maxErrorRetry {
+		return false
+	}
+	if !a.isThrottled(err, state.Response) && !a.AttainsBackoffRetryPolicy.ShouldRetry(err, state.Attempts) {
+		return false
+	}
+	// Retrying before the server asks is pointless, give up when it asks to wait longer than the max delay
+	if delay, ok := a.retryAfter(state); ok && delay > a.maxDelay() {
+		return false
+	}
+	// Give up when waiting for the next retry would exceed the total delay
+	if a.maxTotalDelayInMillis > 0 {
+		delay := a.GetDelayBeforeNextRetryWithResponse(err, state)
+		return state.TotalDelay+delay <= time.Duration(a.maxTotalDelayInMillis)*time.Millisecond
+	}
+	return true
+}
+
+func (a AttainsThrottlingRetryPolicy) GetDelayBeforeNextRetryWithResponse(err errors.AttainsError, state *RetryState) time.Duration {
+	if delay, ok := a.retryAfter(state); ok {
+		if maxDelay := a.maxDelay(); delay > maxDelay {
+			return maxDelay
+		}
+		return delay
+	}
+	return a.AttainsBackoffRetryPolicy.GetDelayBeforeNextRetryInMillis(err, state.Attempts)
+}
+
+func (a AttainsThrottlingRetryPolicy) retryAfter(state *RetryState) (time.Duration, bool) {
+	if state.Response == nil {
+		return 0, false
+	}
+	return ParseRetryAfter(state.Response.Header)
+}
+
+func (a AttainsThrottlingRetryPolicy) maxDelay() time.Duration {
+	return time.Duration(a.maxDelayInMillis) * time.Millisecond
+}
+
+// NewAttainsThrottlingRetryPolicy create a throttling aware retry policy, http 429 and the given
+// service codes are treated as throttling, maxTotalDelay <= 0 means no limit of the total delay while a
+// single delay never exceeds maxDelay
+func NewAttainsThrottlingRetryPolicy(maxRetry int, maxDelay, base, maxTotalDelay int64, throttlingCodes ...int64) AttainsRetryPolicy {
+	codes := map[int64]struct{}{
+		http.StatusTooManyRequests: {},
+	}
+	for _, code := range throttlingCodes {
+		codes[code] = struct{}{}
+	}
+	return &AttainsThrottlingRetryPolicy{
+		AttainsBackoffRetryPolicy: AttainsBackoffRetryPolicy{maxRetry, maxDelay, base},
+		maxTotalDelayInMillis:     maxTotalDelay,
+		throttlingCodes:           codes,
+	}
+}
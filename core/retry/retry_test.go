@@ -0,0 +1,112 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+
+package retry_test
+
+import (
+	"github.com/attains/attainscloud-sdk-go/core/errors"
+	"github.com/attains/attainscloud-sdk-go/core/retry"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantMin time.Duration
+		wantMax time.Duration
+		wantOk  bool
+	}{
+		{"Missing", "", 0, 0, false},
+		{"Seconds", "5", 5 * time.Second, 5 * time.Second, true},
+		{"SecondsWithSpaces", " 7 ", 7 * time.Second, 7 * time.Second, true},
+		{"Zero", "0", 0, 0, true},
+		{"Negative", "-3", 0, 0, false},
+		{"Invalid", "soon", 0, 0, false},
+		// The http-date has a precision of one second
+		{"FutureDate", time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second, true},
+		{"PastDate", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			header := http.Header{}
+			if c.value != "" {
+				header.Set(retry.RetryAfterHeader, c.value)
+			}
+			delay, ok := retry.ParseRetryAfter(header)
+			if ok != c.wantOk || delay < c.wantMin || delay > c.wantMax {
+				t.Errorf("ParseRetryAfter(%q) = %v, %v, want [%v, %v], %v", c.value, delay, ok, c.wantMin, c.wantMax, c.wantOk)
+			}
+		})
+	}
+}
+
+// throttled the state of a call whose last attempt was answered 429, with the Retry-After if not empty
+func throttled(attempts int, totalDelay time.Duration, retryAfter string) *retry.RetryState {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set(retry.RetryAfterHeader, retryAfter)
+	}
+	return &retry.RetryState{
+		Attempts:   attempts,
+		Response:   &http.Response{StatusCode: http.StatusTooManyRequests, Header: header},
+		TotalDelay: totalDelay,
+	}
+}
+
+func TestThrottlingRetryPolicy(t *testing.T) {
+	// At most 3 retries, 1s per delay and 2.5s in total, the backoff starts at 400ms
+	policy := retry.NewAttainsThrottlingRetryPolicy(3, 1000, 400, 2500, 4290)
+	tooMany := errors.NewAttainsServiceError(http.StatusTooManyRequests, "too many requests")
+	cases := []struct {
+		name      string
+		err       error
+		state     *retry.RetryState
+		wantRetry bool
+		wantDelay time.Duration
+	}{
+		{"Backoff", tooMany, throttled(0, 0, ""), true, 400 * time.Millisecond},
+		{"RetryAfter", tooMany, throttled(0, 0, "1"), true, time.Second},
+		{"RetryAfterBeyondMaxDelay", tooMany, throttled(0, 0, "2"), false, time.Second},
+		{"ThrottlingCode", errors.NewAttainsServiceError(4290, "throttled"), &retry.RetryState{Attempts: 1}, true, 800 * time.Millisecond},
+		{"NotThrottled", errors.NewAttainsServiceError(http.StatusNotFound, "not found"), &retry.RetryState{}, false, 400 * time.Millisecond},
+		{"MaxRetries", tooMany, throttled(3, 0, ""), false, time.Second},
+		{"WithinMaxTotalDelay", tooMany, throttled(2, 1500*time.Millisecond, "1"), true, time.Second},
+		{"BeyondMaxTotalDelay", tooMany, throttled(2, 1600*time.Millisecond, "1"), false, time.Second},
+		{"BackoffBeyondMaxTotalDelay", tooMany, throttled(2, 1600*time.Millisecond, ""), false, time.Second},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := retry.ShouldRetry(policy, c.err, c.state); got != c.wantRetry {
+				t.Errorf("ShouldRetry = %v, want %v", got, c.wantRetry)
+			}
+			if got := retry.GetDelayBeforeNextRetry(policy, c.err, c.state); got != c.wantDelay {
+				t.Errorf("GetDelayBeforeNextRetry = %v, want %v", got, c.wantDelay)
+			}
+		})
+	}
+}
+
+func TestThrottlingRetryPolicyWithoutTotalLimit(t *testing.T) {
+	policy := retry.NewAttainsThrottlingRetryPolicy(3, 1000, 400, 0)
+	state := throttled(2, time.Hour, "1")
+	if !retry.ShouldRetry(policy, errors.NewAttainsServiceError(http.StatusTooManyRequests, ""), state) {
+		t.Error("ShouldRetry = false, want true when the total delay is not limited")
+	}
+}
@@ -6,3 +6,8 @@ require (
 	github.com/attains/attainscloud-sdk-go/core v0.0.0-20231126161644-e1ee4f1d05ab
 	github.com/attains/attainscloud-sdk-go/services/sms v0.0.0-20231126162149-4b61ac7c510f
 )
+
+replace (
+	github.com/attains/attainscloud-sdk-go/core => ../../core
+	github.com/attains/attainscloud-sdk-go/services/sms => ../../services/sms
+)
@@ -3,3 +3,5 @@ module github.com/attains/attainscloud-sdk-go/services/sms
 go 1.13
 
 require github.com/attains/attainscloud-sdk-go/core v0.0.0-20231126161644-e1ee4f1d05ab
+
+replace github.com/attains/attainscloud-sdk-go/core => ../../core
@@ -0,0 +1,219 @@
+/*
+ * Copyright 2023 Attains Cloud, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ * except in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the
+ * License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+ * either express or implied. See the License for the specific language governing permissions
+ * and limitations under the License.
+ *
+ * visit: https://cloud.attains.cn
+ *
+ */
+package v1
+
+import (
+	"context"
+	"github.com/attains/attainscloud-sdk-go/core/httpclient"
+)
+
+// submit run fn on the worker pool of the client, or in a new goroutine if the client has no pool
+func (s *SmsClient) submit(ctx context.Context, fn func() (interface{}, error)) *httpclient.Future {
+	if client, ok := s.acHttpClient.(httpclient.AttainsAsyncHttpClient); ok {
+		return client.Submit(ctx, fn)
+	}
+	return httpclient.RunAsync(ctx, fn)
+}
+
+// CreateSignatureFuture the pending result of CreateSignatureAsync
+type CreateSignatureFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for CreateSignature to complete and return its result
+func (f CreateSignatureFuture) Get(ctx context.Context) (*CreateSignatureResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*CreateSignatureResult), nil
+}
+
+// CreateSignatureAsync Create s sms signature on the worker pool of the client.
+func (s *SmsClient) CreateSignatureAsync(ctx context.Context, args *CreateSignatureArgs, opts ...httpclient.CallOption) CreateSignatureFuture {
+	return CreateSignatureFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.CreateSignature(ctx, args, opts...)
+	})}
+}
+
+// QuerySignatureFuture the pending result of QuerySignatureAsync
+type QuerySignatureFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for QuerySignature to complete and return its result
+func (f QuerySignatureFuture) Get(ctx context.Context) (*QuerySignatureResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*QuerySignatureResult), nil
+}
+
+// QuerySignatureAsync Query a sms signature on the worker pool of the client.
+func (s *SmsClient) QuerySignatureAsync(ctx context.Context, args *QuerySignatureArgs, opts ...httpclient.CallOption) QuerySignatureFuture {
+	return QuerySignatureFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.QuerySignature(ctx, args, opts...)
+	})}
+}
+
+// GetSignatureListFuture the pending result of GetSignatureListAsync
+type GetSignatureListFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for GetSignatureList to complete and return its result
+func (f GetSignatureListFuture) Get(ctx context.Context) (GetSignatureListResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(GetSignatureListResult), nil
+}
+
+// GetSignatureListAsync Get the list of sms signature on the worker pool of the client.
+func (s *SmsClient) GetSignatureListAsync(ctx context.Context, args *GetSignatureListArgs, opts ...httpclient.CallOption) GetSignatureListFuture {
+	return GetSignatureListFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.GetSignatureList(ctx, args, opts...)
+	})}
+}
+
+// CreateTemplateFuture the pending result of CreateTemplateAsync
+type CreateTemplateFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for CreateTemplate to complete and return its result
+func (f CreateTemplateFuture) Get(ctx context.Context) (*CreateTemplateResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*CreateTemplateResult), nil
+}
+
+// CreateTemplateAsync create a sms template on the worker pool of the client.
+func (s *SmsClient) CreateTemplateAsync(ctx context.Context, args *CreateTemplateArgs, opts ...httpclient.CallOption) CreateTemplateFuture {
+	return CreateTemplateFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.CreateTemplate(ctx, args, opts...)
+	})}
+}
+
+// QueryTemplateFuture the pending result of QueryTemplateAsync
+type QueryTemplateFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for QueryTemplate to complete and return its result
+func (f QueryTemplateFuture) Get(ctx context.Context) (*QueryTemplateResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*QueryTemplateResult), nil
+}
+
+// QueryTemplateAsync query a sms template on the worker pool of the client.
+func (s *SmsClient) QueryTemplateAsync(ctx context.Context, args *QueryTemplateArgs, opts ...httpclient.CallOption) QueryTemplateFuture {
+	return QueryTemplateFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.QueryTemplate(ctx, args, opts...)
+	})}
+}
+
+// GetTemplateListFuture the pending result of GetTemplateListAsync
+type GetTemplateListFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for GetTemplateList to complete and return its result
+func (f GetTemplateListFuture) Get(ctx context.Context) (GetTemplateListResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(GetTemplateListResult), nil
+}
+
+// GetTemplateListAsync get the list of sms template on the worker pool of the client.
+func (s *SmsClient) GetTemplateListAsync(ctx context.Context, args *GetTemplateListArgs, opts ...httpclient.CallOption) GetTemplateListFuture {
+	return GetTemplateListFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.GetTemplateList(ctx, args, opts...)
+	})}
+}
+
+// DeleteTemplateFuture the pending result of DeleteTemplateAsync
+type DeleteTemplateFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for DeleteTemplate to complete and return its result
+func (f DeleteTemplateFuture) Get(ctx context.Context) (*DeleteTemplateResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*DeleteTemplateResult), nil
+}
+
+// DeleteTemplateAsync delete a sms template on the worker pool of the client.
+func (s *SmsClient) DeleteTemplateAsync(ctx context.Context, args *DeleteTemplateArgs, opts ...httpclient.CallOption) DeleteTemplateFuture {
+	return DeleteTemplateFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.DeleteTemplate(ctx, args, opts...)
+	})}
+}
+
+// SendSmsFuture the pending result of SendSmsAsync
+type SendSmsFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for SendSms to complete and return its result
+func (f SendSmsFuture) Get(ctx context.Context) (*SendSmsResult, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*SendSmsResult), nil
+}
+
+// SendSmsAsync send sms on the worker pool of the client.
+func (s *SmsClient) SendSmsAsync(ctx context.Context, args *SendSmsArgs, opts ...httpclient.CallOption) SendSmsFuture {
+	return SendSmsFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.SendSms(ctx, args, opts...)
+	})}
+}
+
+// GetBalanceFuture the pending result of GetBalanceAsync
+type GetBalanceFuture struct {
+	*httpclient.Future
+}
+
+// Get wait for GetBalance to complete and return its result
+func (f GetBalanceFuture) Get(ctx context.Context) (int64, error) {
+	r, err := f.Future.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.(int64), nil
+}
+
+// GetBalanceAsync get sms account balance on the worker pool of the client.
+func (s *SmsClient) GetBalanceAsync(ctx context.Context, opts ...httpclient.CallOption) GetBalanceFuture {
+	return GetBalanceFuture{s.submit(ctx, func() (interface{}, error) {
+		return s.GetBalance(ctx, opts...)
+	})}
+}
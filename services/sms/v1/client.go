@@ -38,8 +38,12 @@ func New(client httpclient.AttainsHttpClient) *SmsClient {
 	}
 }
 
-func (s *SmsClient) newRequest(ctx context.Context) httpclient.AttainsRequest {
-	return httpclient.NewDefaultAttainsRequest(ctx, nil).WithEndpoint(DefaultEndpoint)
+func (s *SmsClient) newRequest(ctx context.Context, opts []httpclient.CallOption) httpclient.AttainsExtendedRequest {
+	q := httpclient.NewDefaultAttainsRequest(ctx, nil).(httpclient.AttainsExtendedRequest)
+	q.WithEndpoint(DefaultEndpoint)
+	q.WithService(ServiceName)
+	httpclient.ApplyCallOptions(q, opts...)
+	return q
 }
 
 func (s *SmsClient) sendRequest(q httpclient.AttainsRequest, r interface{}) error {
@@ -47,13 +51,13 @@ func (s *SmsClient) sendRequest(q httpclient.AttainsRequest, r interface{}) erro
 }
 
 // CreateSignature Create s sms signature.
-func (s *SmsClient) CreateSignature(ctx context.Context, args *CreateSignatureArgs) (*CreateSignatureResult, error) {
+func (s *SmsClient) CreateSignature(ctx context.Context, args *CreateSignatureArgs, opts ...httpclient.CallOption) (*CreateSignatureResult, error) {
 	var err error
 	body, err := json.Marshal(args)
 	if err != nil {
 		return nil, err
 	}
-	q := s.newRequest(ctx).
+	q := s.newRequest(ctx, opts).
 		WithPath(RequestUriSignatureApply).
 		WithMethod(http.MethodPost).
 		WithBodyBytes(body)
@@ -63,13 +67,13 @@ func (s *SmsClient) CreateSignature(ctx context.Context, args *CreateSignatureAr
 }
 
 // QuerySignature Query a sms signature.
-func (s *SmsClient) QuerySignature(ctx context.Context, args *QuerySignatureArgs) (*QuerySignatureResult, error) {
+func (s *SmsClient) QuerySignature(ctx context.Context, args *QuerySignatureArgs, opts ...httpclient.CallOption) (*QuerySignatureResult, error) {
 	var err error
 	query, err := structutil.StructToUrlValues(args, true)
 	if err != nil {
 		return nil, err
 	}
-	q := s.newRequest(ctx).
+	q := s.newRequest(ctx, opts).
 		WithPath(RequestUriSignatureQuery).
 		WithQuery(query)
 	r := new(QuerySignatureResult)
@@ -78,13 +82,13 @@ func (s *SmsClient) QuerySignature(ctx context.Context, args *QuerySignatureArgs
 }
 
 // GetSignatureList Get the list of sms signature.
-func (s *SmsClient) GetSignatureList(ctx context.Context, args *GetSignatureListArgs) (GetSignatureListResult, error) {
+func (s *SmsClient) GetSignatureList(ctx context.Context, args *GetSignatureListArgs, opts ...httpclient.CallOption) (GetSignatureListResult, error) {
 	var err error
 	query, err := structutil.StructToUrlValues(args, true)
 	if err != nil {
 		return nil, err
 	}
-	q := s.newRequest(ctx).
+	q := s.newRequest(ctx, opts).
 		WithPath(RequestUriSignatureList).
 		WithQuery(query)
 	r := &GetSignatureListResult{}
@@ -93,13 +97,13 @@ func (s *SmsClient) GetSignatureList(ctx context.Context, args *GetSignatureList
 }
 
 // CreateTemplate create a sms template.
-func (s *SmsClient) CreateTemplate(ctx context.Context, args *CreateTemplateArgs) (*CreateTemplateResult, error) {
+func (s *SmsClient) CreateTemplate(ctx context.Context, args *CreateTemplateArgs, opts ...httpclient.CallOption) (*CreateTemplateResult, error) {
 	var err error
 	body, err := json.Marshal(args)
 	if err != nil {
 		return nil, err
 	}
-	q := s.newRequest(ctx).
+	q := s.newRequest(ctx, opts).
 		WithPath(RequestUriTemplateCreate).
 		WithMethod(http.MethodPost).
 		WithBodyBytes(body)
@@ -109,8 +113,9 @@ func (s *SmsClient) CreateTemplate(ctx context.Context, args *CreateTemplateArgs
 }
 
 // QueryTemplate query a sms template.
-func (s *SmsClient) QueryTemplate(ctx context.Context, args *QueryTemplateArgs) (*QueryTemplateResult, error) {
-	q := s.newRequest(ctx).
+func (s *SmsClient) QueryTemplate(ctx context.Context, args *QueryTemplateArgs, opts ...httpclient.CallOption) (*QueryTemplateResult, error) {
+	q := s.newRequest(ctx, opts).
+		WithRoute(RouteTemplateQuery).
 		WithPath(RequestUriTemplateQuery + metadata.UriSeparator + args.TemplateId)
 	r := new(QueryTemplateResult)
 	err := s.sendRequest(q, r)
@@ -118,13 +123,13 @@ func (s *SmsClient) QueryTemplate(ctx context.Context, args *QueryTemplateArgs)
 }
 
 // GetTemplateList get the list of sms template.
-func (s *SmsClient) GetTemplateList(ctx context.Context, args *GetTemplateListArgs) (GetTemplateListResult, error) {
+func (s *SmsClient) GetTemplateList(ctx context.Context, args *GetTemplateListArgs, opts ...httpclient.CallOption) (GetTemplateListResult, error) {
 	var err error
 	query, err := structutil.StructToUrlValues(args, true)
 	if err != nil {
 		return nil, err
 	}
-	q := s.newRequest(ctx).
+	q := s.newRequest(ctx, opts).
 		WithPath(RequestUriTemplateList).
 		WithQuery(query)
 	r := new(GetTemplateListResult)
@@ -133,8 +138,9 @@ func (s *SmsClient) GetTemplateList(ctx context.Context, args *GetTemplateListAr
 }
 
 // DeleteTemplate delete a sms template.
-func (s *SmsClient) DeleteTemplate(ctx context.Context, args *DeleteTemplateArgs) (*DeleteTemplateResult, error) {
-	q := s.newRequest(ctx).
+func (s *SmsClient) DeleteTemplate(ctx context.Context, args *DeleteTemplateArgs, opts ...httpclient.CallOption) (*DeleteTemplateResult, error) {
+	q := s.newRequest(ctx, opts).
+		WithRoute(RouteTemplateDelete).
 		WithPath(RequestUriTemplateDelete + metadata.UriSeparator + args.TemplateId).
 		WithMethod(http.MethodDelete)
 	r := new(DeleteTemplateResult)
@@ -143,13 +149,13 @@ func (s *SmsClient) DeleteTemplate(ctx context.Context, args *DeleteTemplateArgs
 }
 
 // SendSms send sms.
-func (s *SmsClient) SendSms(ctx context.Context, args *SendSmsArgs) (*SendSmsResult, error) {
+func (s *SmsClient) SendSms(ctx context.Context, args *SendSmsArgs, opts ...httpclient.CallOption) (*SendSmsResult, error) {
 	var err error
 	body, err := json.Marshal(args)
 	if err != nil {
 		return nil, err
 	}
-	q := s.newRequest(ctx).
+	q := s.newRequest(ctx, opts).
 		WithPath(RequestUriSendSms).
 		WithMethod(http.MethodPost).
 		WithBodyBytes(body)
@@ -159,8 +165,8 @@ func (s *SmsClient) SendSms(ctx context.Context, args *SendSmsArgs) (*SendSmsRes
 }
 
 // GetBalance get sms account balance.
-func (s *SmsClient) GetBalance(ctx context.Context) (int64, error) {
-	q := s.newRequest(ctx).WithPath(RequestUriGetBalance)
+func (s *SmsClient) GetBalance(ctx context.Context, opts ...httpclient.CallOption) (int64, error) {
+	q := s.newRequest(ctx, opts).WithPath(RequestUriGetBalance)
 	r := int64(0)
 	err := s.sendRequest(q, &r)
 	return r, err
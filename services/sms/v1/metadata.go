@@ -19,6 +19,8 @@ package v1
 
 const (
 	DefaultEndpoint = "smsv1.bj.api.attains.cloud"
+	// ServiceName the service label of the metrics
+	ServiceName = "sms"
 )
 
 const (
@@ -31,6 +33,10 @@ const (
 	RequestUriTemplateDelete = "/template/delete"
 	RequestUriTemplateList   = "/template/list"
 
+	// The routes of the paths with the template id for the metrics
+	RouteTemplateQuery  = RequestUriTemplateQuery + "/{templateId}"
+	RouteTemplateDelete = RequestUriTemplateDelete + "/{templateId}"
+
 	RequestUriSendSms    = "/send"
 	RequestUriGetBalance = "/balance"
 )